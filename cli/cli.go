@@ -6,8 +6,10 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/glovenkevin/go-git-puller/commands"
+	"github.com/glovenkevin/go-git-puller/puller"
 	"go.uber.org/zap"
 )
 
@@ -25,6 +27,63 @@ type Cli struct {
 	// Exclude Project by Name
 	ExProject sliceName
 
+	// How deep the directory is walked looking for repository
+	MaxDepth int
+
+	// Gitlab project listing
+	Owned          bool
+	WithShared     bool
+	MembershipOnly bool
+	RateLimit      float64
+
+	// Retry policy of git network operation
+	Retries      int
+	RetryBackoff time.Duration
+	RetryJitter  float64
+
+	// Time limit of the whole run and of every repository
+	Timeout     time.Duration
+	RepoTimeout time.Duration
+
+	Resume      bool
+	Workers     int
+	Incremental bool
+	Depth       int
+	Submodules  bool
+	LFS         bool
+	AllRemotes  bool
+	PushOrigin  bool
+	AllBranches bool
+	Divergence  string
+
+	// Commands run inside every repository
+	PreUpdate  string
+	PostClone  string
+	PostUpdate string
+
+	// Command and its arguments run by exec action
+	ExecArgs []string
+
+	// Backup restored by restore action
+	Backup string
+
+	// Manifest file read by sync-manifest action and written by export action
+	Manifest string
+
+	// What export action walk: local or gitlab
+	ExportFrom string
+
+	// Write every event of the run as JSON lines into the file
+	ReportJSON string
+	reportFile *os.File
+
+	// Log output
+	LogFormat     string
+	LogFile       string
+	LogLevel      string
+	LogMaxSize    int
+	LogMaxBackups int
+
 	// Credential
 	Username string
 	Password string
@@ -49,8 +108,22 @@ var (
 	ErrDirectoryNotValid   = errors.New("Directory is not valid")
 	ErrGroupNameNotValid   = errors.New("Group name not valid")
 	ErrProjectNameNotValid = errors.New("Project name not valid")
+	ErrExecArgsNotProvided = errors.New("Command for exec action is not provided")
 )
 
+// Actions that can be executed
+var actions = map[string]struct{}{
+	"clone-gitlab":  {},
+	"update-gitlab": {},
+	"update":        {},
+	"sync-manifest": {},
+	"export":        {},
+	"exec":          {},
+	"restore":       {},
+	"version":       {},
+	"usage":         {},
+}
+
 // Return new Cli struct for consuming
 // parameter that needed to do actions
 func New() *Cli {
@@ -67,18 +140,11 @@ func New() *Cli {
 // And then parse it
 func (c *Cli) Parse() error {
 
-	action := os.Args[1]
-	if action == "" {
+	if len(os.Args) < 2 || os.Args[1] == "" {
 		return ErrActionNotProvided
 	}
 
-	actions := map[string]struct{}{
-		"clone-gitlab":  {},
-		"update-gitlab": {},
-		"update":        {},
-		"version":       {},
-		"usage":         {},
-	}
+	action := os.Args[1]
 
 	if _, ok := actions[action]; !ok {
 		return ErrActionNotFound
@@ -101,12 +167,49 @@ func (c *Cli) Parse() error {
 
 	subCommand.Var(&c.ExGroups, "eg", "Exclude group specified by group name")
 	subCommand.Var(&c.ExProject, "ep", "Exclude project specified by project name")
+	subCommand.IntVar(&c.MaxDepth, "max-depth", 0, "How deep the directory is walked looking for repository, 0 means no limit")
+
+	subCommand.BoolVar(&c.Owned, "owned", false, "Include projects in your personal namespace")
+	subCommand.BoolVar(&c.WithShared, "with-shared", false, "Include projects shared into a group")
+	subCommand.BoolVar(&c.MembershipOnly, "membership-only", false, "Only sync projects you are a member of")
+	subCommand.Float64Var(&c.RateLimit, "rps", 0, "Maximum gitlab api requests per second")
+
+	subCommand.IntVar(&c.Retries, "retries", puller.DefaultRetryPolicy.MaxAttempts, "Maximum attempt of clone/fetch/pull")
+	subCommand.DurationVar(&c.RetryBackoff, "retry-backoff", puller.DefaultRetryPolicy.Backoff, "Wait before retrying, doubled on every attempt")
+	subCommand.Float64Var(&c.RetryJitter, "retry-jitter", puller.DefaultRetryPolicy.Jitter, "Fraction of the retry wait being randomized")
+
+	subCommand.DurationVar(&c.Timeout, "timeout", 0, "Maximum duration of the whole run")
+	subCommand.DurationVar(&c.RepoTimeout, "repo-timeout", 0, "Maximum duration of a single clone/pull")
+	subCommand.IntVar(&c.Workers, "workers", 4, "Number of repository cloned/updated at the same time")
+	subCommand.BoolVar(&c.Resume, "resume", false, "Continue the previous gitlab run from its journal")
+	subCommand.BoolVar(&c.Incremental, "incremental", false, "Skip gitlab repository without activity since the previous run")
+	subCommand.IntVar(&c.Depth, "depth", 1, "Number of commit cloned by the gitlab actions, 0 clones the full history")
+	subCommand.BoolVar(&c.Submodules, "submodules", false, "Initialize and update submodules recursively after clone and pull")
+	subCommand.BoolVar(&c.LFS, "lfs", false, "Download Git LFS objects after clone and pull")
+	subCommand.BoolVar(&c.AllRemotes, "all-remotes", false, "Fetch every remote and pull from upstream when it's present")
+	subCommand.BoolVar(&c.PushOrigin, "push-origin", false, "Push the branch pulled from upstream into origin")
+	subCommand.BoolVar(&c.AllBranches, "all-branches", false, "Fast-forward every local branch tracking a remote branch")
+	subCommand.StringVar(&c.Divergence, "divergence", puller.DivergenceSkip, "Strategy for diverged branch: skip, merge or rebase")
+
+	subCommand.StringVar(&c.PreUpdate, "pre-update", "", "Command run inside every repository before it's updated")
+	subCommand.StringVar(&c.PostClone, "post-clone", "", "Command run inside every repository after it's cloned")
+	subCommand.StringVar(&c.PostUpdate, "post-update", "", "Command run inside every repository after it's updated")
 
 	subCommand.StringVar(&c.Rootdir, "path", ".", "Set Working directory root path")
 	subCommand.BoolVar(&c.Verbose, "verbose", false, "Activate verbose/debug print")
+	subCommand.StringVar(&c.LogFormat, "log-format", logFormatConsole, "Log format: json or console")
+	subCommand.StringVar(&c.LogFile, "log-file", "", "Write log into the file instead of the terminal")
+	subCommand.StringVar(&c.LogLevel, "log-level", "info", "Log level: error, warn, info or debug")
+	subCommand.IntVar(&c.LogMaxSize, "log-max-size", defaultLogMaxSize, "Rotate the log file when its size exceed the limit (MB)")
+	subCommand.IntVar(&c.LogMaxBackups, "log-max-backups", defaultLogMaxBackups, "Number of rotated log file being kept")
 	subCommand.BoolVar(&c.Hardreset, "hard-reset", false, "Set false to use softreset or otherwise")
+	subCommand.StringVar(&c.Manifest, "manifest", "", "Manifest file (YAML or JSON) read by sync-manifest and written by export")
+	subCommand.StringVar(&c.ExportFrom, "export-from", puller.ExportLocal, "What export walk: local or gitlab")
+	subCommand.StringVar(&c.Backup, "backup", "", "Backup restored by restore action, latest for the newest one")
+	subCommand.StringVar(&c.ReportJSON, "report-json", "", "Write every event of the run as JSON lines into the file")
 
 	_ = subCommand.Parse(os.Args[2:])
+	c.ExecArgs = subCommand.Args()
 	return c.Validate()
 }
 
@@ -116,11 +219,24 @@ func (c *Cli) Parse() error {
 // Root directory must valid or will using current dir
 func (c *Cli) Validate() error {
 
+	if _, ok := actions[c.Action]; !ok {
+		return ErrActionNotFound
+	}
+
 	if c.Action == "version" || c.Action == "usage" {
 		return nil
 	}
 
-	if c.Token == "" && (c.Username == "" || c.Password == "") {
+	if err := c.validateLog(); err != nil {
+		return err
+	}
+
+	if c.Action == "exec" && len(c.ExecArgs) == 0 {
+		return ErrExecArgsNotProvided
+	}
+
+	localExport := c.Action == "export" && c.ExportFrom != puller.ExportGitlab
+	if c.Action != "exec" && c.Action != "restore" && !localExport && c.Token == "" && (c.Username == "" || c.Password == "") {
 		return ErrCredentialNotFound
 	}
 
@@ -150,19 +266,71 @@ func (c *Cli) Validate() error {
 }
 
 func (c *Cli) NewCommand(zLog *zap.Logger) (*commands.Command, error) {
-	command, err := commands.New(&commands.Options{
+	var observers []puller.Observer
+	if c.ReportJSON != "" {
+		file, err := os.Create(c.ReportJSON)
+		if err != nil {
+			return nil, err
+		}
+		c.reportFile = file
+		observers = append(observers, puller.NewJSONObserver(file))
+	}
+
+	command, err := commands.New(&puller.Options{
 		Verbose: c.Verbose,
 		Action:  c.Action,
 		Dir:     c.Rootdir,
 		Baseurl: c.Baseurl,
-		Auth: &commands.Auth{
+		Auth: &puller.Auth{
 			Username: c.Username,
 			Password: c.Password,
 		},
 		Logs:       zLog,
 		Exgroups:   ([]string)(c.ExGroups),
 		Exprojects: ([]string)(c.ExProject),
+		MaxDepth:   c.MaxDepth,
+
+		Owned:          c.Owned,
+		WithShared:     c.WithShared,
+		MembershipOnly: c.MembershipOnly,
+		RateLimit:      c.RateLimit,
+		Retry: &puller.RetryPolicy{
+			MaxAttempts: c.Retries,
+			Backoff:     c.RetryBackoff,
+			MaxBackoff:  puller.DefaultRetryPolicy.MaxBackoff,
+			Jitter:      c.RetryJitter,
+		},
+		Timeout:     c.Timeout,
+		RepoTimeout: c.RepoTimeout,
+		Resume:      c.Resume,
+		Workers:     c.Workers,
+		Incremental: c.Incremental,
+		Depth:       c.Depth,
+		Submodules:  c.Submodules,
+		LFS:         c.LFS,
+		AllRemotes:  c.AllRemotes,
+		PushOrigin:  c.PushOrigin,
+		AllBranches: c.AllBranches,
+		Divergence:  c.Divergence,
+		Hooks: puller.Hooks{
+			PreUpdate:  c.PreUpdate,
+			PostClone:  c.PostClone,
+			PostUpdate: c.PostUpdate,
+		},
+		ExecArgs:   c.ExecArgs,
+		Backup:     c.Backup,
+		Manifest:   c.Manifest,
+		ExportFrom: c.ExportFrom,
+		Observers:  observers,
 	})
 
 	return command, err
 }
+
+// Close the file opened for the command
+func (c *Cli) Close() error {
+	if c.reportFile == nil {
+		return nil
+	}
+	return c.reportFile.Close()
+}
@@ -82,6 +82,21 @@ func TestValidate(t *testing.T) {
 			},
 			Expected: nil,
 		},
+		{
+			Name: "Exec Without Command",
+			Param: Cli{
+				Action: "exec",
+			},
+			Expected: ErrExecArgsNotProvided,
+		},
+		{
+			Name: "Exec Without Credential",
+			Param: Cli{
+				Action:   "exec",
+				ExecArgs: []string{"git", "status"},
+			},
+			Expected: nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/glovenkevin/go-git-puller/cli"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 func main() {
@@ -17,27 +19,34 @@ func main() {
 		return
 	}
 
-	// Set Logger config
-	conf := zap.NewProductionConfig()
-	conf.Encoding = "console"
-	conf.EncoderConfig = zap.NewDevelopmentEncoderConfig()
-	conf.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	conf.DisableStacktrace = true
-	conf.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-
-	if cli.Verbose {
-		conf.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	zlog, err := cli.NewLogger()
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
-
-	var zlog, _ = conf.Build()
+	defer func() {
+		_ = zlog.Sync()
+	}()
 
 	cmd, err := cli.NewCommand(zlog)
+	defer func() {
+		_ = cli.Close()
+	}()
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	err = cmd.Execute()
+	// Stop scheduling new work on Ctrl-C/SIGTERM. After the first signal
+	// the default handler is restored, so a second one kill the program.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err = cmd.ExecuteContext(ctx)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	logFormatConsole = "console"
+	logFormatJSON    = "json"
+)
+
+var (
+	ErrLogFormatNotValid = errors.New("Log format not valid, use json or console")
+	ErrLogLevelNotValid  = errors.New("Log level not valid, use error, warn, info or debug")
+)
+
+var logLevels = map[string]zapcore.Level{
+	"error": zap.ErrorLevel,
+	"warn":  zap.WarnLevel,
+	"info":  zap.InfoLevel,
+	"debug": zap.DebugLevel,
+}
+
+// Validate the log format and level, empty value means the default one
+func (c *Cli) validateLog() error {
+	if c.LogFormat != "" && c.LogFormat != logFormatConsole && c.LogFormat != logFormatJSON {
+		return ErrLogFormatNotValid
+	}
+
+	if _, ok := logLevels[c.LogLevel]; c.LogLevel != "" && !ok {
+		return ErrLogLevelNotValid
+	}
+
+	return nil
+}
+
+// Build zap logger from the log parameter. Console format printed into the
+// terminal is colored, while log written into the file is rotated by its size.
+func (c *Cli) NewLogger() (*zap.Logger, error) {
+	level := zap.InfoLevel
+	if c.LogLevel != "" {
+		level = logLevels[c.LogLevel]
+	}
+	if c.Verbose {
+		level = zap.DebugLevel
+	}
+
+	encoderConf := zap.NewDevelopmentEncoderConfig()
+	if c.LogFormat == logFormatJSON {
+		encoderConf = zap.NewProductionEncoderConfig()
+		encoderConf.EncodeTime = zapcore.ISO8601TimeEncoder
+	}
+
+	var writer zapcore.WriteSyncer = zapcore.Lock(os.Stderr)
+	if c.LogFile != "" {
+		file, err := newRotateFile(c.LogFile, c.LogMaxSize, c.LogMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		writer = zapcore.AddSync(file)
+	} else if c.LogFormat != logFormatJSON {
+		encoderConf.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+
+	encoder := zapcore.NewConsoleEncoder(encoderConf)
+	if c.LogFormat == logFormatJSON {
+		encoder = zapcore.NewJSONEncoder(encoderConf)
+	}
+
+	core := zapcore.NewCore(encoder, writer, zap.NewAtomicLevelAt(level))
+	return zap.New(core, zap.ErrorOutput(zapcore.Lock(os.Stderr))), nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLog(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Input     *Cli
+		ErrOutput error
+	}{
+		{
+			Name:      "DefaultValue",
+			Input:     &Cli{},
+			ErrOutput: nil,
+		},
+		{
+			Name:      "JsonDebug",
+			Input:     &Cli{LogFormat: "json", LogLevel: "debug"},
+			ErrOutput: nil,
+		},
+		{
+			Name:      "FormatNotValid",
+			Input:     &Cli{LogFormat: "xml"},
+			ErrOutput: ErrLogFormatNotValid,
+		},
+		{
+			Name:      "LevelNotValid",
+			Input:     &Cli{LogLevel: "trace"},
+			ErrOutput: ErrLogLevelNotValid,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			require.Equal(t, test.ErrOutput, test.Input.validateLog())
+		})
+	}
+}
+
+func TestNewLoggerJsonFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "puller.log")
+	c := &Cli{LogFormat: "json", LogLevel: "warn", LogFile: path}
+
+	log, err := c.NewLogger()
+	require.NoError(t, err)
+	log.Info("filtered")
+	log.Warn("kept")
+	require.NoError(t, log.Sync())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "filtered")
+	require.Contains(t, string(data), `"msg":"kept"`)
+}
+
+func TestRotateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "puller.log")
+	r, err := newRotateFile(path, 1, 2)
+	require.NoError(t, err)
+
+	line := []byte(strings.Repeat("a", megabyte/2) + "\n")
+	for i := 0; i < 6; i++ {
+		_, err := r.Write(line)
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Sync())
+
+	require.FileExists(t, path)
+	require.FileExists(t, path+".1")
+	require.FileExists(t, path+".2")
+	require.NoFileExists(t, path+".3")
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	defaultLogMaxSize    = 10
+	defaultLogMaxBackups = 3
+	megabyte             = 1024 * 1024
+)
+
+// rotateFile is a log file that being rotated when its size exceeds the limit.
+// The rotated file is renamed to <file>.1, the older one to <file>.2 and so on,
+// only the configured number of backups is kept.
+type rotateFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// Open the log file for appending. Max size is in megabytes.
+func newRotateFile(path string, maxSize int, maxBackups int) (*rotateFile, error) {
+	if maxSize <= 0 {
+		maxSize = defaultLogMaxSize
+	}
+	if maxBackups < 0 {
+		maxBackups = defaultLogMaxBackups
+	}
+
+	r := &rotateFile{
+		path:       path,
+		maxSize:    int64(maxSize) * megabyte,
+		maxBackups: maxBackups,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotateFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotateFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Sync()
+}
+
+func (r *rotateFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Shift the backups then start a new file
+func (r *rotateFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	if r.maxBackups == 0 {
+		_ = os.Remove(r.path)
+	} else {
+		_ = os.Remove(r.backup(r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(r.backup(i), r.backup(i+1))
+		}
+		if err := os.Rename(r.path, r.backup(1)); err != nil {
+			return err
+		}
+	}
+
+	return r.open()
+}
+
+func (r *rotateFile) backup(i int) string {
+	return fmt.Sprintf("%v.%v", r.path, i)
+}
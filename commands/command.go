@@ -1,191 +1,220 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
-	"strings"
+	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"github.com/glovenkevin/go-git-puller/puller"
 	"go.uber.org/zap"
 )
 
-type Options struct {
-	// Flag for activating logging while the program running
-	Verbose bool
-
-	// Define action to be executed in command
-	Action string
-
-	// Define root directory of the action
-	Dir string
-
-	// Exclude Group name, separated by commas
-	Exgroups []string
-
-	// Exclude Project name, separated by commas
-	Exprojects []string
-
-	// a flag that used to make decision wether it's need to be hard reset
-	// before the pull being executed
-	Hardreset bool
-
-	// Define authentication used for interacting with git repository
-	Auth *Auth
-
-	// Define base url (usefull for update-gitlab or clone project)
-	Baseurl string
-
-	// Set the zap logger
-	Logs *zap.Logger
-}
-
+// Command run the action of the command line on a puller.Syncer,
+// showing the progress and printing the result of the run
 type Command struct {
-	verbose    bool
-	action     string
-	dir        string
-	exGroups   map[string]struct{}
-	exProjects map[string]struct{}
-	auth       *Auth
-	hardReset  bool
-	baseurl    string
-	bar        *progressbar.ProgressBar
+	action   string
+	verbose  bool
+	dir      string
+	timeout  time.Duration
+	manifest string
+	backup   string
+	syncer   *puller.Syncer
+	progress *progress
 
 	// default logger for the package command (zap logger)
 	log *zap.Logger
 }
 
-type Auth struct {
-	// Username being used for authentication with git.
-	// If this token was set, then this field will have default value "token" (acording to go-git docs to use like this)
-	Username string
-
-	// Password for authentication with git
-	// If token was set, then it's gonna be put in here
-	Password string
-}
-
 var (
-	ErrCommandNotFound    = errors.New("Command not found/unrecognize")
-	ErrCredentialNotFound = errors.New("Credential has not been set completely")
-	ErrActionNotFound     = errors.New("Action not been initialize")
-	ErrLogsNotDefined     = errors.New("Zap logger has not been defined")
-	ErrDirNotExist        = errors.New("Directory not valid/exist")
+	ErrCommandNotFound = errors.New("Command not found/unrecognize")
+	ErrActionNotFound  = errors.New("Action not been initialize")
 )
 
 // Generate new command struct for executing update
-func New(opt *Options) (*Command, error) {
+func New(opt *puller.Options) (*Command, error) {
+	// The syncer fall back to a no-op logger, the command line always log
+	if opt != nil && opt.Logs == nil && opt.Action != "version" && opt.Action != "usage" {
+		return nil, puller.ErrLogsNotDefined
+	}
 
-	if err := validate(opt); err != nil {
+	c := &Command{}
+	syncer, err := puller.NewSyncer(opt, func(e puller.Event) {
+		if c.progress != nil {
+			c.progress.OnEvent(e)
+		}
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	exProject := make(map[string]struct{})
-	for _, val := range opt.Exprojects {
-		exProject[val] = struct{}{}
+	c.action = opt.Action
+	c.verbose = opt.Verbose
+	c.dir = opt.Dir
+	c.timeout = opt.Timeout
+	c.manifest = opt.Manifest
+	c.backup = opt.Backup
+	c.syncer = syncer
+	c.log = opt.Logs
+	return c, nil
+}
+
+func (c *Command) getCommandDispatcher() map[string]func(context.Context) error {
+	return map[string]func(context.Context) error{
+		"update": func(ctx context.Context) error {
+			return c.sync(ctx, c.syncer.Update)
+		},
+		"update-gitlab": func(ctx context.Context) error {
+			return c.sync(ctx, c.syncer.UpdateGitlab)
+		},
+		"clone-gitlab": func(ctx context.Context) error {
+			return c.sync(ctx, c.syncer.CloneGitlab)
+		},
+		"sync-manifest": func(ctx context.Context) error {
+			return c.sync(ctx, c.syncer.SyncManifest)
+		},
+		"export": func(ctx context.Context) error {
+			return c.Export(ctx)
+		},
+		"exec": func(ctx context.Context) error {
+			return c.Exec(ctx)
+		},
+		"restore": func(ctx context.Context) error {
+			return c.Restore(ctx)
+		},
+		"version": func(ctx context.Context) error {
+			return PrintVersion()
+		},
+		"usage": func(ctx context.Context) error {
+			usage()
+			return nil
+		},
 	}
+}
+
+// Execute action based on action key provided
+func (c *Command) Execute() error {
+	return c.ExecuteContext(context.Background())
+}
+
+// Execute action with the given context. When the context is canceled
+// no new repository is scheduled, running clone is rolled back
+// and the summary of the finished repositories is printed.
+func (c *Command) ExecuteContext(ctx context.Context) error {
 
-	exGroups := make(map[string]struct{})
-	for _, val := range opt.Exgroups {
-		exGroups[val] = struct{}{}
+	dispatcher := c.getCommandDispatcher()
+	action, ok := dispatcher[c.action]
+	if !ok {
+		return ErrCommandNotFound
 	}
 
-	c := Command{
-		verbose:    opt.Verbose,
-		action:     opt.Action,
-		auth:       opt.Auth,
-		dir:        opt.Dir,
-		exGroups:   exGroups,
-		exProjects: exProject,
-		hardReset:  opt.Hardreset,
-		baseurl:    opt.Baseurl,
-		log:        opt.Logs,
+	if c.log == nil {
+		c.log = zap.NewNop()
 	}
 
-	if !opt.Verbose {
-		c.bar = progressbar.Default(1)
-		c.bar.Describe("Start executing action ...")
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
 	}
 
-	return &c, nil
+	return action(ctx)
 }
 
-// Validate given options is enough to do the task
-// Credential, action performed, directory and the logs
-func validate(opt *Options) error {
-
-	if opt.Action == "version" || opt.Action == "usage" {
-		return nil
+// Run the clone or the update along with the progress display and print the summary of the run.
+// The progress display is disabled on verbose mode where every operation is printed by the logger instead.
+func (c *Command) sync(ctx context.Context, run func(context.Context) (*puller.Result, error)) error {
+	if !c.verbose {
+		c.progress = newStdoutProgress(c.dir)
 	}
 
-	if match, _ := regexp.MatchString(`[/\\]{2,}$`, opt.Dir); match {
-		return ErrDirNotExist
-	}
+	res, err := run(ctx)
+	c.progress.finish()
+	c.interrupted(ctx)
+	printSummary(os.Stdout, res)
+	return err
+}
 
-	if strings.HasSuffix(opt.Dir, "\\") || strings.HasSuffix(opt.Dir, "/") {
-		opt.Dir = strings.TrimSuffix(opt.Dir, "/")
-		opt.Dir = strings.TrimSuffix(opt.Dir, "\\")
+// Tell the run was stopped before every repository is finished
+func (c *Command) interrupted(ctx context.Context) {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		fmt.Printf("\nRun timed out after %v, only finished repositories are reported\n", c.timeout)
+	case context.Canceled:
+		fmt.Println("\nRun was interrupted, only finished repositories are reported")
 	}
+}
 
-	if _, err := os.Stat(opt.Dir); err != nil {
-		return ErrDirNotExist
-	}
+// Write the manifest of the local tree or of the gitlab group tree into the manifest file,
+// so the workspace can be reproduced by sync-manifest. The manifest is printed when the file isn't set.
+func (c *Command) Export(ctx context.Context) error {
+	start := time.Now()
+	c.log.Debug("Start exporting manifest", zap.String("action", "export"))
 
-	if opt.Auth == nil ||
-		(opt.Auth != nil && (opt.Auth.Username == "" || opt.Auth.Password == "")) {
-		return ErrCredentialNotFound
+	manifest, err := c.syncer.Export(ctx)
+	if err != nil {
+		return err
 	}
-
-	if opt.Logs == nil {
-		return ErrLogsNotDefined
+	if err := puller.WriteManifest(manifest, c.manifest, os.Stdout); err != nil {
+		return err
 	}
 
+	c.log.Debug("Finish exporting manifest", zap.String("action", "export"), zap.Int("repos", len(manifest.Repos)),
+		zap.Duration("duration", time.Since(start)))
 	return nil
 }
 
-func (c *Command) getCommandDispatcher() map[string]func() error {
-	return map[string]func() error{
-		"update": func() error {
-			return c.UpdateGit()
-		},
-		"update-gitlab": func() error {
-			return c.UpdateGitlab()
-		},
-		"clone-gitlab": func() error {
-			return c.CloneGitlab()
-		},
-		"version": func() error {
-			return PrintVersion()
-		},
-		"usage": func() error {
-			usage()
-			return nil
-		},
+// Run the command inside every repository found in the directory. Output is prefixed
+// with the repository path and the exit code of every repository is summarized.
+func (c *Command) Exec(ctx context.Context) error {
+	results, err := c.syncer.Exec(ctx, os.Stdout)
+	if results != nil {
+		c.interrupted(ctx)
+		printExecSummary(os.Stdout, results)
 	}
+	return err
 }
 
-// Execute action based on action key provided
-func (c *Command) Execute() error {
-
-	dispatcher := c.getCommandDispatcher()
-	err := dispatcher[c.action]()
-	if err != nil {
-		return err
+// List the backups of every repository inside the directory, or restore the
+// backup when it's set. Latest backup means the newest backup of every repository.
+func (c *Command) Restore(ctx context.Context) error {
+	if c.backup == "" {
+		backups, err := c.syncer.Backups(ctx)
+		if err != nil {
+			return err
+		}
+		printBackups(os.Stdout, backups)
+		return nil
 	}
 
-	return nil
+	results, err := c.syncer.Restore(ctx, c.backup)
+	printRestore(os.Stdout, results)
+	return err
 }
 
 func usage() {
 	msg := `
 Usage: go-git-puller.exe <action> [-t <token>] [-U <username>] [-P <password>]
-			[-path <path>] [-u <URL>] [-verbose] [-eg <groupname>] [-ep <projectname>]
+			[-path <path>] [-u <URL>] [-verbose] [-eg <groupname>] [-ep <projectname>] [-max-depth <number>]
+			[-log-format <json|console>] [-log-file <file>] [-log-level <level>] [-report-json <file>]
+			[-timeout <duration>] [-repo-timeout <duration>] [-workers <number>] [-resume] [-incremental] [-depth <number>] [-submodules] [-lfs] [-all-remotes] [-push-origin] [-all-branches] [-divergence <strategy>]
+			[-owned] [-with-shared] [-membership-only] [-rps <number>]
+			[-retries <number>] [-retry-backoff <duration>] [-retry-jitter <fraction>]
+			[-pre-update <command>] [-post-clone <command>] [-post-update <command>]
+       go-git-puller.exe exec [-path <path>] [-workers <number>] [-eg <dirname>] [-ep <reponame>] [-max-depth <number>] [--] <command> [args...]
+       go-git-puller.exe sync-manifest -manifest <file> [-path <path>] [-u <URL>] [-t <token>] [-workers <number>] [...update parameter]
+       go-git-puller.exe export [-manifest <file>] [-export-from <local|gitlab>] [-path <path>] [-u <URL>] [-t <token>] [-eg <groupname>] [-ep <projectname>]
+       go-git-puller.exe restore [-path <path>] [-eg <dirname>] [-ep <reponame>] [-backup <name|latest>]
 
 Action
   clone-gitlab	Clone whole gitlab project with tree structure
   update-gitlab	Update gitlab project in local recursively, clone the project if doesn't exist or update it if present in your local mechine 
   update	Update local project recursively
+  sync-manifest	Clone the repositories of the manifest that are missing, update the existing one and report the one not in the manifest
+  export	Write the manifest of the local repositories (or the gitlab tree) with their url, branch and commit
+  exec		Run the command inside every local repository, output is prefixed with the repository path
+  restore	List the backups taken before the update reset the worktree, or restore one of them with -backup
   version	Show go-git-puller version
   usage		Show command line parameter
 
@@ -193,7 +222,28 @@ Action Parameter
   -u,-url	Default would be https://gitlab.com/, if you have local repository with specific url you can put it in here.
   -path		Set the target path. The default value is current path
   -verbose	Flag for activating debug mode (Print every the shit out of it)
-  -hard-reset	Flag for enabling hard reset on project/local repo when update action being executed
+  -log-format	Log format: json or console (default console)
+  -log-file	Write log into the file instead of the terminal, rotated by its size
+  -log-level	Log level: error, warn, info or debug (default info)
+  -log-max-size	Rotate the log file when its size exceed the limit in MB (default 10)
+  -log-max-backups	Number of rotated log file being kept (default 3)
+  -report-json	Write every event of the run (discovered, started, finished, error) as JSON lines into the file
+  -hard-reset	Flag for enabling hard reset on project/local repo when update action being executed, uncommitted change is always backed up first
+  -manifest	Manifest file (YAML or JSON) declaring the repositories of the workspace, read by sync-manifest and written by export (printed when empty)
+  -export-from	What export action walk: local (default) for the repositories inside -path, gitlab for the gitlab group tree
+  -backup	Backup restored by restore action, latest for the newest backup of every repository
+  -timeout	Maximum duration of the whole run, Ex: 30m (default no limit)
+  -repo-timeout	Maximum duration of a single clone/pull, Ex: 5m (default no limit)
+  -workers	Number of repository cloned/updated (or running the exec command) at the same time (default 4)
+  -incremental	Skip repository without activity on gitlab since the previous update-gitlab run
+  -depth		Number of commit cloned by clone-gitlab/update-gitlab (default 1), 0 clones the full history. Shallow clone can't be updated afterward
+  -submodules	Initialize and update submodules recursively after the repository is cloned or updated
+  -lfs		Download Git LFS objects and replace the LFS pointer files after the repository is cloned or updated
+  -all-remotes	Fetch every remote and pull the branch from the upstream remote when it's present (fork), instead of origin
+  -push-origin	Push the branch pulled from upstream into origin (with -all-remotes)
+  -all-branches	Fast-forward every local branch tracking a remote branch without checking it out, diverged branch is reported
+  -divergence	Strategy for the checked out branch diverged from the remote: skip (default), merge or rebase. Conflict is aborted and reported
+  -resume	Continue the previous clone-gitlab/update-gitlab run from its journal (<path>/.go-git-puller/state.json)
   -version	Show go-git-puller current version
 
 Authentication parameter
@@ -202,12 +252,43 @@ Authentication parameter
   -t,-token	Using token for authentication
 
 Exclude Project/Group parameter
-  -eg		Exclude group from being pull/update by name
-  -ep		Exclude project from being pull/update by name
+  -eg		Exclude group from being pull/update by name (directory name for update and exec)
+  -ep		Exclude project from being pull/update by name (repository directory name for update and exec)
+  -max-depth	How deep the directory is walked looking for repository by update, exec and restore (default no limit)
+  		Directory matching the .pullerignore file (gitignore syntax) of the walked directory is skipped, node_modules and vendor are skipped by default
+
+Gitlab Project Listing parameter
+  -owned		Include projects inside your personal namespace (placed under users/<username>)
+  -with-shared		Include projects shared into a group from another namespace
+  -membership-only	Only sync projects you are a member of instead of walking every group
+  -rps			Maximum gitlab api requests per second (default is no fixed limit)
+
+Retry parameter
+  -retries		Maximum attempt for clone/fetch/pull on transient network failure (default 3)
+  -retry-backoff	Wait before retrying, doubled on every attempt (default 2s)
+  -retry-jitter		Fraction of the wait being randomized (default 0.2)
+
+Hook parameter (run inside the repository directory, failure doesn't stop the run)
+  -pre-update	Command run before the repository is updated
+  -post-clone	Command run after the repository is cloned
+  -post-update	Command run after the repository is updated
+  Environment: GGP_REPO_PATH, GGP_REPO_NAME, GGP_ACTION, GGP_OLD_SHA, GGP_NEW_SHA, GGP_REMOTE_URL
 
 Example: 
   #Clone Whole Gitlab Tree
   go-git-puller.exe -c clone-gitlab -t 124asdf -u http://localhost/
+
+  #Make the workspace match the manifest
+  go-git-puller.exe sync-manifest -manifest workspace.yaml -path D:/workplace -u http://localhost/ -t 124asdf
+
+  #Snapshot the workspace, so it can be reproduced by sync-manifest on another machine
+  go-git-puller.exe export -path D:/workplace -manifest workspace.yaml
+
+  #Show the status of every repository
+  go-git-puller.exe exec -path D:/workplace -- git status --short
+
+  #Restore the change discarded by the last update
+  go-git-puller.exe restore -path D:/workplace/api -backup latest
 `
 	fmt.Println(msg)
 }
@@ -1,9 +1,11 @@
 package commands
 
 import (
+	"context"
 	"os"
 	"testing"
 
+	"github.com/glovenkevin/go-git-puller/puller"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -27,57 +29,57 @@ func TestNewCommands(t *testing.T) {
 
 	tests := []struct {
 		Name      string
-		Input     *Options
+		Input     *puller.Options
 		CmdStrct  *Command
 		ErrOutput error
 	}{
 		{
 			Name:      "NoParameterGiven",
-			Input:     &Options{},
+			Input:     &puller.Options{},
 			CmdStrct:  nil,
 			ErrOutput: ErrActionNotFound,
 		},
 		{
 			Name: "NoAuthGiven",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
 				Dir:    ".",
 				Logs:   Log,
 			},
 			CmdStrct:  nil,
-			ErrOutput: ErrCredentialNotFound,
+			ErrOutput: puller.ErrCredentialNotFound,
 		},
 		{
 			Name: "AuthNotFullySet1",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Username: "",
 				},
 				Dir:  ".",
 				Logs: Log,
 			},
 			CmdStrct:  nil,
-			ErrOutput: ErrCredentialNotFound,
+			ErrOutput: puller.ErrCredentialNotFound,
 		},
 		{
 			Name: "AuthNotFullySet2",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Password: "",
 				},
 				Dir:  ".",
 				Logs: Log,
 			},
 			CmdStrct:  nil,
-			ErrOutput: ErrCredentialNotFound,
+			ErrOutput: puller.ErrCredentialNotFound,
 		},
 		{
 			Name: "AuthNotFullySet3",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Username: "asdf",
 					Password: "",
 				},
@@ -85,26 +87,26 @@ func TestNewCommands(t *testing.T) {
 				Logs: Log,
 			},
 			CmdStrct:  nil,
-			ErrOutput: ErrCredentialNotFound,
+			ErrOutput: puller.ErrCredentialNotFound,
 		},
 		{
 			Name: "DirectoryNotSet",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Username: "user",
 					Password: "pass",
 				},
 				Logs: Log,
 			},
 			CmdStrct:  nil,
-			ErrOutput: ErrDirNotExist,
+			ErrOutput: puller.ErrDirNotExist,
 		},
 		{
 			Name: "DirectorySetToFault",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Username: "user",
 					Password: "pass",
 				},
@@ -112,13 +114,13 @@ func TestNewCommands(t *testing.T) {
 				Dir:  "test2",
 			},
 			CmdStrct:  nil,
-			ErrOutput: ErrDirNotExist,
+			ErrOutput: puller.ErrDirNotExist,
 		},
 		{
 			Name: "DirectoryIsValid",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Username: "user",
 					Password: "pass",
 				},
@@ -127,19 +129,15 @@ func TestNewCommands(t *testing.T) {
 			},
 			CmdStrct: &Command{
 				action: "test",
-				auth: &Auth{
-					Username: "user",
-					Password: "pass",
-				},
-				dir: "test",
+				dir:    "test",
 			},
 			ErrOutput: nil,
 		},
 		{
 			Name: "LogNotSet",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Username: "user",
 					Password: "pass",
 				},
@@ -147,13 +145,13 @@ func TestNewCommands(t *testing.T) {
 				Logs: nil,
 			},
 			CmdStrct:  nil,
-			ErrOutput: ErrLogsNotDefined,
+			ErrOutput: puller.ErrLogsNotDefined,
 		},
 		{
 			Name: "AllFullSetUp",
-			Input: &Options{
+			Input: &puller.Options{
 				Action: "test",
-				Auth: &Auth{
+				Auth: &puller.Auth{
 					Username: "user",
 					Password: "pass",
 				},
@@ -162,11 +160,7 @@ func TestNewCommands(t *testing.T) {
 			},
 			CmdStrct: &Command{
 				action: "test",
-				auth: &Auth{
-					Username: "user",
-					Password: "pass",
-				},
-				dir: ".",
+				dir:    ".",
 			},
 			ErrOutput: nil,
 		},
@@ -185,30 +179,30 @@ func TestNewCommands(t *testing.T) {
 func TestExecuteAction(t *testing.T) {
 	tests := []struct {
 		Name      string
-		Input     *Command
+		Input     *puller.Options
 		ErrOutput error
 	}{
 		{
 			Name: "ActionNotRecognize",
-			Input: &Command{
-				action: "tes",
-				dir:    ".",
+			Input: &puller.Options{
+				Action: "tes",
+				Dir:    ".",
 			},
 			ErrOutput: ErrCommandNotFound,
 		},
 		{
 			Name: "ActionNotBeenSet",
-			Input: &Command{
-				action: "",
-				dir:    ".",
+			Input: &puller.Options{
+				Action: "",
+				Dir:    ".",
 			},
 			ErrOutput: ErrCommandNotFound,
 		},
 		{
 			Name: "AllSet",
-			Input: &Command{
-				action: "update",
-				dir:    ".",
+			Input: &puller.Options{
+				Action: "update",
+				Dir:    ".",
 			},
 			ErrOutput: nil,
 		},
@@ -216,8 +210,34 @@ func TestExecuteAction(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.Name, func(t *testing.T) {
-			err := test.Input.Execute()
-			require.Equal(t, test.ErrOutput, err)
+			test.Input.Logs = Log
+			test.Input.Auth = &puller.Auth{Username: "user", Password: "pass"}
+			cmd, err := New(test.Input)
+			require.Nil(t, err)
+			require.Equal(t, test.ErrOutput, cmd.Execute())
 		})
 	}
 }
+
+func TestExecuteContextCanceled(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.Mkdir(dir+"/project", os.ModePerm))
+
+	cmd, err := New(&puller.Options{
+		Action:  "update",
+		Dir:     dir,
+		Verbose: true,
+		Logs:    Log,
+		Auth: &puller.Auth{
+			Username: "user",
+			Password: "pass",
+		},
+	})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = cmd.ExecuteContext(ctx)
+	require.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glovenkevin/go-git-puller/puller"
+	"golang.org/x/term"
+)
+
+const (
+	progressBarWidth = 30
+	progressInterval = 150 * time.Millisecond
+	defaultWidth     = 80
+)
+
+// progress render the overall count of the run along with a line for every
+// running operation, using the events of the run. On a terminal the lines are
+// redrawn in place, otherwise a plain line is printed when a repository is finished.
+type progress struct {
+	mu    sync.Mutex
+	out   io.Writer
+	tty   bool
+	width int
+	root  string
+
+	total      int
+	done       int
+	discovered int
+	slots      []*task
+
+	lines   int
+	stop    chan struct{}
+	stopped chan struct{}
+	closed  bool
+}
+
+// task is a running clone/update shown as a line of the progress
+type task struct {
+	path   string
+	name   string
+	phase  string
+	detail string
+}
+
+// Create progress printed into stdout, detecting whether it is a terminal
+func newStdoutProgress(root string) *progress {
+	fd := int(os.Stdout.Fd())
+	tty := term.IsTerminal(fd)
+
+	width := defaultWidth
+	if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+		width = w
+	}
+	return newProgress(os.Stdout, tty, width, root)
+}
+
+// Create progress printed into the writer. Path of the repository
+// is shown relative to the root directory.
+func newProgress(out io.Writer, tty bool, width int, root string) *progress {
+	p := &progress{
+		out:   out,
+		tty:   tty,
+		width: width,
+		root:  strings.TrimSuffix(root, "/") + "/",
+	}
+
+	if tty {
+		p.stop = make(chan struct{})
+		p.stopped = make(chan struct{})
+		go p.loop()
+	}
+	return p
+}
+
+// Update the state of the progress from the event of the run
+func (p *progress) OnEvent(e puller.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch e.Type {
+	case puller.EventRepoDiscovered:
+		p.discovered++
+	case puller.EventDiscoveryFinished:
+		p.total = e.Count
+		if !p.tty {
+			fmt.Fprintf(p.out, "Found %v repositories\n", e.Count)
+		}
+	case puller.EventCloneStarted:
+		p.start(e.Path, puller.PhaseClone)
+	case puller.EventUpdateStarted:
+		p.start(e.Path, puller.PhaseFetch)
+	case puller.EventCloneProgress, puller.EventUpdateProgress:
+		if t := p.task(e.Path); t != nil {
+			t.phase = e.Phase
+			t.detail = e.Message
+		}
+	case puller.EventCloneFinished, puller.EventUpdateFinished:
+		p.finished(e.Result)
+	}
+}
+
+// Show the operation on a free line
+func (p *progress) start(path string, phase string) {
+	t := &task{
+		path:  path,
+		name:  strings.TrimPrefix(path, p.root),
+		phase: phase,
+	}
+
+	for i, slot := range p.slots {
+		if slot == nil {
+			p.slots[i] = t
+			return
+		}
+	}
+	p.slots = append(p.slots, t)
+}
+
+func (p *progress) task(path string) *task {
+	for _, t := range p.slots {
+		if t != nil && t.path == path {
+			return t
+		}
+	}
+	return nil
+}
+
+// Release the line of the operation and count the repository as done
+func (p *progress) finished(res *puller.RepoResult) {
+	for i, t := range p.slots {
+		if t != nil && t.path == res.Path {
+			p.slots[i] = nil
+		}
+	}
+
+	p.done++
+	if p.tty {
+		return
+	}
+
+	count := fmt.Sprint(p.done)
+	if p.total > 0 {
+		count += fmt.Sprintf("/%v", p.total)
+	}
+	fmt.Fprintf(p.out, "[%v] %v %v %v (%v)\n", count, res.Action, strings.TrimPrefix(res.Path, p.root),
+		res.Status, res.Duration.Round(time.Millisecond))
+}
+
+// Stop redrawing and print the final state of the progress,
+// it's safe on a nil progress (progress display is disabled)
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	if p.tty {
+		close(p.stop)
+		<-p.stopped
+	}
+}
+
+func (p *progress) loop() {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	defer close(p.stopped)
+
+	for {
+		select {
+		case <-ticker.C:
+			p.render()
+		case <-p.stop:
+			p.render()
+			return
+		}
+	}
+}
+
+// Redraw every line in place, the cursor is moved back
+// to the first line drawn by the previous render
+func (p *progress) render() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lines := []string{p.header()}
+	if !p.closed {
+		for _, t := range p.slots {
+			if t != nil {
+				lines = append(lines, t.line())
+			}
+		}
+	}
+
+	var b strings.Builder
+	if p.lines > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", p.lines)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(&b, "\r\x1b[2K%v\n", truncate(line, p.width-1))
+	}
+	if extra := p.lines - len(lines); extra > 0 {
+		b.WriteString(strings.Repeat("\r\x1b[2K\n", extra))
+		fmt.Fprintf(&b, "\x1b[%dA", extra)
+	}
+	p.lines = len(lines)
+
+	_, _ = io.WriteString(p.out, b.String())
+}
+
+// Overall line, the repository count is unknown until the discovery is finished
+func (p *progress) header() string {
+	if p.total == 0 {
+		return fmt.Sprintf("%v/%v repositories, discovering ...", p.done, p.discovered)
+	}
+
+	filled := progressBarWidth * p.done / p.total
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	return fmt.Sprintf("[%v%v] %v/%v repositories", strings.Repeat("=", filled),
+		strings.Repeat(" ", progressBarWidth-filled), p.done, p.total)
+}
+
+func (t *task) line() string {
+	line := fmt.Sprintf("  %-9v %v", t.phase, t.name)
+	if t.detail != "" {
+		line += "  " + t.detail
+	}
+	return line
+}
+
+// Cut the line to the terminal width, so the line is never wrapped
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if width <= 0 || len(runes) <= width {
+		return line
+	}
+	return string(runes[:width])
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/glovenkevin/go-git-puller/puller"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressPlain(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newProgress(out, false, defaultWidth, "/root/")
+
+	p.OnEvent(puller.Event{Type: puller.EventRepoDiscovered, Path: "/root/group/first"})
+	p.OnEvent(puller.Event{Type: puller.EventRepoDiscovered, Path: "/root/group/second"})
+	p.OnEvent(puller.Event{Type: puller.EventDiscoveryFinished, Count: 2})
+
+	p.OnEvent(puller.Event{Type: puller.EventUpdateStarted, Path: "/root/group/first", Action: puller.ActionUpdate})
+	p.OnEvent(puller.Event{Type: puller.EventCloneStarted, Path: "/root/group/second", Action: puller.ActionClone})
+	require.Len(t, p.slots, 2)
+	p.OnEvent(puller.Event{Type: puller.EventUpdateFinished, Result: &puller.RepoResult{Path: "/root/group/first", Action: puller.ActionUpdate, Status: puller.StatusUpToDate, Duration: time.Second}})
+
+	// The released line is reused by the next operation
+	p.OnEvent(puller.Event{Type: puller.EventUpdateStarted, Path: "/root/group/third", Action: puller.ActionUpdate})
+	require.Equal(t, "group/third", p.slots[0].name)
+	p.OnEvent(puller.Event{Type: puller.EventCloneFinished, Result: &puller.RepoResult{Path: "/root/group/second", Action: puller.ActionClone, Status: puller.StatusFailed}})
+	p.finish()
+
+	require.Equal(t, "Found 2 repositories\n"+
+		"[1/2] update group/first up-to-date (1s)\n"+
+		"[2/2] clone group/second failed (0s)\n", out.String())
+}
+
+func TestProgressPhase(t *testing.T) {
+	p := newProgress(&bytes.Buffer{}, false, defaultWidth, "/root")
+
+	p.OnEvent(puller.Event{Type: puller.EventUpdateStarted, Path: "/root/repo", Action: puller.ActionUpdate})
+	require.Equal(t, puller.PhaseFetch, p.slots[0].phase)
+
+	p.OnEvent(puller.Event{Type: puller.EventUpdateProgress, Path: "/root/repo", Action: puller.ActionUpdate, Phase: puller.PhaseCheckout})
+	require.Equal(t, puller.PhaseCheckout, p.slots[0].phase)
+
+	p.OnEvent(puller.Event{Type: puller.EventUpdateProgress, Path: "/root/repo", Action: puller.ActionUpdate, Phase: puller.PhaseFetch, Message: "Receiving objects 45% (123/270)"})
+	require.Equal(t, "  fetch     repo  Receiving objects 45% (123/270)", p.slots[0].line())
+
+	// Event of other repository doesn't change the line
+	p.OnEvent(puller.Event{Type: puller.EventCloneProgress, Path: "/root/other", Action: puller.ActionClone, Phase: puller.PhaseClone})
+	require.Equal(t, puller.PhaseFetch, p.slots[0].phase)
+}
+
+func TestProgressRender(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := &progress{out: out, tty: true, width: 50, root: "/root/"}
+	p.OnEvent(puller.Event{Type: puller.EventDiscoveryFinished, Count: 4})
+	p.OnEvent(puller.Event{Type: puller.EventUpdateFinished, Result: &puller.RepoResult{Path: "/root/skipped", Action: puller.ActionUpdate, Status: puller.StatusSkipped}})
+	p.OnEvent(puller.Event{Type: puller.EventCloneStarted, Path: "/root/group/a-very-long-repository-name-being-cut", Action: puller.ActionClone})
+
+	p.render()
+	require.Equal(t, "\r\x1b[2K[=======                       ] 1/4 repositories\n"+
+		"\r\x1b[2K  clone     group/a-very-long-repository-name-bei\n", out.String())
+
+	// Finished line is cleared and the cursor is moved back
+	out.Reset()
+	p.OnEvent(puller.Event{Type: puller.EventCloneFinished, Result: &puller.RepoResult{Path: "/root/group/a-very-long-repository-name-being-cut", Action: puller.ActionClone, Status: puller.StatusSuccess}})
+	p.render()
+	require.Equal(t, "\x1b[2A\r\x1b[2K[===============               ] 2/4 repositories\n"+
+		"\r\x1b[2K\n\x1b[1A", out.String())
+}
+
+func TestProgressDiscovering(t *testing.T) {
+	p := &progress{out: &bytes.Buffer{}, root: "/root/"}
+	p.OnEvent(puller.Event{Type: puller.EventRepoDiscovered, Path: "/root/first"})
+	p.OnEvent(puller.Event{Type: puller.EventRepoDiscovered, Path: "/root/second"})
+	p.OnEvent(puller.Event{Type: puller.EventUpdateFinished, Result: &puller.RepoResult{Path: "/root/first", Action: puller.ActionUpdate, Status: puller.StatusSuccess}})
+
+	require.Equal(t, "1/2 repositories, discovering ...", p.header())
+	require.Equal(t, "1/2 r", truncate(p.header(), 5))
+}
+
+func TestProgressNil(t *testing.T) {
+	var p *progress
+	p.finish()
+}
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/glovenkevin/go-git-puller/puller"
+)
+
+// lines of the failed hook output printed in the summary
+const hookOutputLines = 10
+
+// Print summary of the run along with the failed repositories,
+// the repositories of the result are already in the order of their path
+func printSummary(w io.Writer, result *puller.Result) {
+	if result == nil || (len(result.Repos) == 0 && len(result.Extras) == 0) {
+		return
+	}
+
+	count := make(map[string]int)
+	failed := make([]puller.RepoResult, 0)
+	failedHooks := make([]puller.RepoResult, 0)
+	failedSubs := make([]puller.RepoResult, 0)
+	failedLFS := make([]puller.RepoResult, 0)
+	diverged := make([]string, 0)
+	backups := make([]puller.RepoResult, 0)
+	for _, res := range result.Repos {
+		if res.Backup != "" {
+			backups = append(backups, res)
+		}
+
+		for _, branch := range res.Branches {
+			if branch.Status == puller.BranchDiverged {
+				diverged = append(diverged, fmt.Sprintf("%v [%v] diverged from %v", res.Path, branch.Name, branch.Upstream))
+			}
+		}
+
+		if res.LFS != nil && res.LFS.Err != nil {
+			failedLFS = append(failedLFS, res)
+		}
+
+		for _, sub := range res.Submodules {
+			if sub.Err != nil {
+				failedSubs = append(failedSubs, res)
+				break
+			}
+		}
+
+		for _, hook := range res.Hooks {
+			if hook.Err != nil {
+				failedHooks = append(failedHooks, res)
+				break
+			}
+		}
+
+		switch res.Status {
+		case puller.StatusFailed:
+			failed = append(failed, res)
+		case puller.StatusSkipped, puller.StatusUpToDate:
+			count[res.Status]++
+		default:
+			count[res.Action]++
+		}
+	}
+
+	fmt.Fprintf(w, "\nSummary: %v cloned, %v updated, %v up-to-date, %v skipped, %v failed\n",
+		count[puller.ActionClone], count[puller.ActionUpdate], count[puller.StatusUpToDate], count[puller.StatusSkipped], len(failed))
+	for _, res := range failed {
+		fmt.Fprintf(w, "  [%v] %v (attempts: %v): %v\n", res.Action, res.Path, res.Attempts, res.Err)
+	}
+
+	if len(result.Extras) > 0 {
+		fmt.Fprintf(w, "\nNot in manifest: %v repositories\n", len(result.Extras))
+		for _, path := range result.Extras {
+			fmt.Fprintf(w, "  %v\n", path)
+		}
+	}
+
+	if len(failedSubs) > 0 {
+		fmt.Fprintf(w, "\nSubmodule failures: %v repositories\n", len(failedSubs))
+		for _, res := range failedSubs {
+			for _, sub := range res.Submodules {
+				if sub.Err != nil {
+					fmt.Fprintf(w, "  %v [%v]: %v\n", res.Path, sub.Path, sub.Err)
+				}
+			}
+		}
+	}
+
+	if len(diverged) > 0 {
+		fmt.Fprintf(w, "\nDiverged branches: %v\n", len(diverged))
+		for _, line := range diverged {
+			fmt.Fprintf(w, "  %v\n", line)
+		}
+	}
+
+	if len(backups) > 0 {
+		fmt.Fprintf(w, "\nBacked up before reset: %v repositories (restore with: restore -path <repo> -backup <name>)\n", len(backups))
+		for _, res := range backups {
+			fmt.Fprintf(w, "  %v: %v\n", res.Path, res.Backup)
+		}
+	}
+
+	if len(failedLFS) > 0 {
+		fmt.Fprintf(w, "\nLFS failures: %v repositories\n", len(failedLFS))
+		for _, res := range failedLFS {
+			fmt.Fprintf(w, "  %v (%v files smudged): %v\n", res.Path, res.LFS.Files, res.LFS.Err)
+		}
+	}
+
+	if len(failedHooks) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\nHook failures: %v repositories\n", len(failedHooks))
+	for _, res := range failedHooks {
+		for _, hook := range res.Hooks {
+			if hook.Err == nil {
+				continue
+			}
+
+			fmt.Fprintf(w, "  [%v] %v: %v\n", hook.Name, res.Path, hook.Err)
+			for _, line := range lastLines(hook.Output, hookOutputLines) {
+				fmt.Fprintf(w, "      %v\n", line)
+			}
+		}
+	}
+}
+
+// Return the last n lines of the output
+func lastLines(output string, n int) []string {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return nil
+	}
+
+	lines := strings.Split(output, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// Print the number of succeeded repositories and every failure of the exec command
+func printExecSummary(w io.Writer, results []puller.ExecResult) {
+	failed := make([]puller.ExecResult, 0)
+	for _, res := range results {
+		if res.ExitCode != 0 {
+			failed = append(failed, res)
+		}
+	}
+
+	fmt.Fprintf(w, "\nSummary: %v succeeded, %v failed\n", len(results)-len(failed), len(failed))
+	for _, res := range failed {
+		fmt.Fprintf(w, "  [exit %v] %v: %v\n", res.ExitCode, res.Path, res.Err)
+	}
+}
+
+// Print the backups of every repository having one, newest first
+func printBackups(w io.Writer, list []puller.RepoBackups) {
+	count := 0
+	for _, repo := range list {
+		fmt.Fprintf(w, "%v\n", repo.Path)
+		for _, backup := range repo.Backups {
+			fmt.Fprintf(w, "  %v  %v files\n", backup.Name, backup.Files)
+			count++
+		}
+	}
+
+	fmt.Fprintf(w, "\n%v backups found\n", count)
+}
+
+// Print the backup restored into every repository
+func printRestore(w io.Writer, results []puller.RestoreResult) {
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Fprintf(w, "Failed to restore %v into %v: %v\n", res.Backup, res.Path, res.Err)
+			continue
+		}
+		fmt.Fprintf(w, "Restored %v into %v (%v files)\n", res.Backup, res.Path, res.Files)
+	}
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/glovenkevin/go-git-puller/puller"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintSummary(t *testing.T) {
+	out := &bytes.Buffer{}
+	printSummary(out, &puller.Result{})
+	require.Empty(t, out.String())
+
+	printSummary(out, &puller.Result{
+		Repos: []puller.RepoResult{
+			{Path: "/root/api", Action: puller.ActionUpdate, Status: puller.StatusSuccess, Backup: "20220401T100000.000Z",
+				Branches: []puller.BranchResult{{Name: "feature", Upstream: "origin/feature", Status: puller.BranchDiverged}}},
+			{Path: "/root/app", Action: puller.ActionClone, Status: puller.StatusSuccess,
+				Submodules: []puller.SubmoduleResult{{Path: "vendor/lib", Err: errors.New("not found")}}},
+			{Path: "/root/assets", Action: puller.ActionUpdate, Status: puller.StatusUpToDate,
+				LFS: &puller.LFSResult{Files: 1, Err: errors.New("object missing")}},
+			{Path: "/root/web", Action: puller.ActionUpdate, Status: puller.StatusFailed, Attempts: 3, Err: errors.New("timeout"),
+				Hooks: []puller.HookResult{{Name: "post-update", Output: "one\ntwo\n", Err: errors.New("exit status 1")}}},
+		},
+		Extras: []string{"/root/old"},
+	})
+	require.Equal(t, "\nSummary: 1 cloned, 1 updated, 1 up-to-date, 0 skipped, 1 failed\n"+
+		"  [update] /root/web (attempts: 3): timeout\n"+
+		"\nNot in manifest: 1 repositories\n"+
+		"  /root/old\n"+
+		"\nSubmodule failures: 1 repositories\n"+
+		"  /root/app [vendor/lib]: not found\n"+
+		"\nDiverged branches: 1\n"+
+		"  /root/api [feature] diverged from origin/feature\n"+
+		"\nBacked up before reset: 1 repositories (restore with: restore -path <repo> -backup <name>)\n"+
+		"  /root/api: 20220401T100000.000Z\n"+
+		"\nLFS failures: 1 repositories\n"+
+		"  /root/assets (1 files smudged): object missing\n"+
+		"\nHook failures: 1 repositories\n"+
+		"  [post-update] /root/web: exit status 1\n"+
+		"      one\n"+
+		"      two\n", out.String())
+}
+
+func TestLastLines(t *testing.T) {
+	require.Nil(t, lastLines("\n", 2))
+	require.Equal(t, []string{"two", "three"}, lastLines("one\ntwo\nthree\n", 2))
+}
+
+func TestPrintExecSummary(t *testing.T) {
+	out := &bytes.Buffer{}
+	printExecSummary(out, []puller.ExecResult{
+		{Path: "/root/first"},
+		{Path: "/root/second", ExitCode: 1, Err: errors.New("exit status 1")},
+	})
+	require.Equal(t, "\nSummary: 1 succeeded, 1 failed\n  [exit 1] /root/second: exit status 1\n", out.String())
+}
+
+func TestPrintBackups(t *testing.T) {
+	out := &bytes.Buffer{}
+	printBackups(out, []puller.RepoBackups{{Path: "/root/api", Backups: []puller.BackupInfo{{Name: "20220401T100000.000Z", Files: 3}}}})
+	require.Equal(t, "/root/api\n  20220401T100000.000Z  3 files\n\n1 backups found\n", out.String())
+
+	out.Reset()
+	printRestore(out, []puller.RestoreResult{
+		{Path: "/root/api", Backup: "20220401T100000.000Z", Files: 3},
+		{Path: "/root/web", Backup: "20220401T100000.000Z", Err: errors.New("conflict")},
+	})
+	require.Equal(t, "Restored 20220401T100000.000Z into /root/api (3 files)\n"+
+		"Failed to restore 20220401T100000.000Z into /root/web: conflict\n", out.String())
+}
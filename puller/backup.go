@@ -0,0 +1,437 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"go.uber.org/zap"
+)
+
+const (
+	// Namespace of the backup refs, the name of the backup is the UTC time it was taken
+	backupRefPrefix  = "refs/go-git-puller/backup/"
+	backupTimeFormat = "20060102T150405.000Z"
+
+	// Restore the newest backup of every repository
+	BackupLatest = "latest"
+)
+
+var (
+	ErrBackupNotFound = errors.New("Backup not found in any repository")
+	ErrRestoreFailed  = errors.New("Restore failed")
+)
+
+// Identity of the backup commit, so the backup doesn't depend on the git config of the user
+const (
+	backupName  = "go-git-puller"
+	backupEmail = "go-git-puller@localhost"
+)
+
+// Snapshot the uncommitted change (untracked file included, ignored file excluded) into a commit
+// on top of HEAD stored on a backup ref, so the reset or the checkout never discard work without record.
+// The commit is written with go-git, the index and the worktree of the repository are untouched.
+// Nothing is created when the worktree is clean, unless the local commits are about to be
+// rewritten (rebase): the backup then keep them as its parent. The name of the backup is returned.
+func (n *node) backup(log *zap.Logger, rewrite bool) (string, error) {
+	repo, err := openRepo(n.path)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("backup: %w", err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", fmt.Errorf("backup: %w", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return "", fmt.Errorf("backup: %w", err)
+	}
+	if status.IsClean() && !rewrite {
+		log.Debug("Worktree is clean, nothing to back up")
+		return "", nil
+	}
+
+	files, err := treeFiles(headCommit)
+	if err != nil {
+		return "", fmt.Errorf("backup: %w", err)
+	}
+	for path, s := range status {
+		if s.Staging == git.Unmodified && s.Worktree == git.Unmodified {
+			continue
+		}
+
+		info, err := os.Lstat(filepath.Join(n.path, path))
+		switch {
+		case os.IsNotExist(err):
+			delete(files, path)
+			continue
+		case err != nil:
+			return "", err
+		case info.IsDir():
+			// Submodule is kept as it's committed, a file replaced by a directory is gone
+			if entry, ok := files[path]; ok && entry.Mode != filemode.Submodule {
+				delete(files, path)
+			}
+			continue
+		}
+
+		entry, err := storeFile(repo.Storer, filepath.Join(n.path, path), info)
+		if err != nil {
+			return "", fmt.Errorf("backup %v: %w", path, err)
+		}
+		files[path] = entry
+	}
+
+	tree, err := storeTree(repo.Storer, files)
+	if err != nil {
+		return "", fmt.Errorf("backup: %w", err)
+	}
+	if tree == headCommit.TreeHash && !rewrite {
+		log.Debug("Worktree is clean, nothing to back up")
+		return "", nil
+	}
+
+	message := "go-git-puller backup before reset"
+	if rewrite {
+		message = "go-git-puller backup before rebase"
+	}
+	sign := object.Signature{Name: backupName, Email: backupEmail, When: time.Now()}
+	commit := &object.Commit{
+		Author:       sign,
+		Committer:    sign,
+		Message:      message,
+		TreeHash:     tree,
+		ParentHashes: []plumbing.Hash{head.Hash()},
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return "", err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+
+	name := time.Now().UTC().Format(backupTimeFormat)
+	ref := plumbing.NewHashReference(plumbing.ReferenceName(backupRefPrefix+name), hash)
+	if err := repo.Storer.SetReference(ref); err != nil {
+		return "", err
+	}
+	log.Info("Uncommitted change backed up", zap.String("backup", name), zap.String("commit", hash.String()),
+		zap.Bool("rewrite", rewrite))
+	return name, nil
+}
+
+// Every file of the commit by its path, submodule included
+func treeFiles(commit *object.Commit) (map[string]object.TreeEntry, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]object.TreeEntry)
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		path, entry, err := walker.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode != filemode.Dir {
+			files[path] = entry
+		}
+	}
+}
+
+// Store the content of the worktree file as a blob, a symlink store its target
+func storeFile(s storer.EncodedObjectStorer, file string, info os.FileInfo) (object.TreeEntry, error) {
+	var (
+		entry   = object.TreeEntry{Mode: filemode.Regular}
+		content []byte
+		err     error
+	)
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		entry.Mode = filemode.Symlink
+		var target string
+		target, err = os.Readlink(file)
+		content = []byte(target)
+	default:
+		if info.Mode()&0111 != 0 {
+			entry.Mode = filemode.Executable
+		}
+		content, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return entry, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return entry, err
+	}
+	if err := w.Close(); err != nil {
+		return entry, err
+	}
+
+	entry.Hash, err = s.SetEncodedObject(obj)
+	return entry, err
+}
+
+// Store the tree of the files, every directory is stored as its own tree
+func storeTree(s storer.EncodedObjectStorer, files map[string]object.TreeEntry) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+	dirs := make(map[string]map[string]object.TreeEntry)
+	for path, entry := range files {
+		if i := strings.Index(path, "/"); i >= 0 {
+			if dirs[path[:i]] == nil {
+				dirs[path[:i]] = make(map[string]object.TreeEntry)
+			}
+			dirs[path[:i]][path[i+1:]] = entry
+			continue
+		}
+		entry.Name = path
+		tree.Entries = append(tree.Entries, entry)
+	}
+
+	for dir, files := range dirs {
+		hash, err := storeTree(s, files)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash})
+	}
+
+	// Git sort the entries by name, a directory as if its name ended with a slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool { return sortName(tree.Entries[i]) < sortName(tree.Entries[j]) })
+
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// List the backups of the repository, newest first
+func (n *node) backups(ctx context.Context) ([]string, error) {
+	out, err := n.git(ctx, "for-each-ref", "--sort=-refname", "--format=%(refname)", backupRefPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w: %v", err, strings.TrimSpace(out))
+	}
+
+	names := make([]string, 0)
+	for _, ref := range strings.Fields(out) {
+		names = append(names, strings.TrimPrefix(ref, backupRefPrefix))
+	}
+	return names, nil
+}
+
+// Return the files changed by the backup, by their git status letter (A, M, D, ...)
+func (n *node) backupFiles(ctx context.Context, name string) (map[string][]string, error) {
+	ref := backupRefPrefix + name
+	out, err := n.git(ctx, "diff", "--name-status", "--no-renames", "-z", ref+"^", ref)
+	if err != nil {
+		return nil, fmt.Errorf("diff backup %v: %w: %v", name, err, strings.TrimSpace(out))
+	}
+
+	files := make(map[string][]string)
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		files[fields[i]] = append(files[fields[i]], fields[i+1])
+	}
+	return files, nil
+}
+
+// Put the uncommitted change of the backup back into the worktree, HEAD is not moved.
+// Only the files changed by the backup are written, they are left unstaged.
+// The current uncommitted change is backed up first, so the restore can be undone.
+func (n *node) restore(ctx context.Context, name string, log *zap.Logger) (int, error) {
+	if _, err := n.backup(log, false); err != nil {
+		return 0, err
+	}
+
+	files, err := n.backupFiles(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var written []string
+	for status, paths := range files {
+		if status == "D" {
+			for _, path := range paths {
+				if err := os.Remove(filepath.Join(n.path, path)); err != nil && !os.IsNotExist(err) {
+					return 0, err
+				}
+			}
+			continue
+		}
+		written = append(written, paths...)
+	}
+
+	if len(written) > 0 {
+		args := append([]string{"checkout", backupRefPrefix + name, "--"}, written...)
+		if out, err := n.git(ctx, args...); err != nil {
+			return 0, fmt.Errorf("restore backup %v: %w: %v", name, err, strings.TrimSpace(out))
+		}
+
+		args = append([]string{"reset", "-q", "--"}, written...)
+		if out, err := n.git(ctx, args...); err != nil {
+			return 0, fmt.Errorf("unstage backup %v: %w: %v", name, err, strings.TrimSpace(out))
+		}
+	}
+	return len(written) + len(files["D"]), nil
+}
+
+// RepoBackups is the backups of a repository, newest first
+type RepoBackups struct {
+	// Local path of the repository
+	Path string
+
+	Backups []BackupInfo
+}
+
+// BackupInfo is a backup taken before the worktree was reset or checked out
+type BackupInfo struct {
+	// Name of the backup, the UTC time it was taken
+	Name string
+
+	// Number of files changed by the backup
+	Files int
+}
+
+// RestoreResult is the result of a backup restored into a repository
+type RestoreResult struct {
+	// Local path of the repository
+	Path string
+
+	// Name of the restored backup
+	Backup string
+
+	// Number of files written or removed by the restore
+	Files int
+
+	// Nil when the backup was restored
+	Err error
+}
+
+// Return every repository inside the directory, used by the backups and the restore
+func (c *engine) localRepos(ctx context.Context) ([]*node, error) {
+	root := makeNode(&nodeOptions{
+		path:       c.dir,
+		exGroups:   c.exGroups,
+		exProjects: c.exProjects,
+		maxDepth:   c.maxDepth,
+		log:        c.log,
+	})
+
+	var repos []*node
+	err := root.walkRepos(ctx, func(repo *node) {
+		repos = append(repos, repo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// Return the backups of every repository having one, newest first
+func (c *engine) listBackups(ctx context.Context, repos []*node) ([]RepoBackups, error) {
+	list := make([]RepoBackups, 0)
+	for _, repo := range repos {
+		names, err := repo.backups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		backups := RepoBackups{Path: repo.path}
+		for _, name := range names {
+			files, err := repo.backupFiles(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+
+			changed := 0
+			for _, paths := range files {
+				changed += len(paths)
+			}
+			backups.Backups = append(backups.Backups, BackupInfo{Name: name, Files: changed})
+		}
+		list = append(list, backups)
+	}
+	return list, nil
+}
+
+// Restore the backup into every repository having it, failed repository
+// doesn't stop the other one from being restored
+func (c *engine) restoreBackups(ctx context.Context, repos []*node, name string) ([]RestoreResult, error) {
+	results := make([]RestoreResult, 0)
+	failed := 0
+	for _, repo := range repos {
+		names, err := repo.backups(ctx)
+		if err != nil {
+			return results, err
+		}
+
+		backup := ""
+		for _, n := range names {
+			if n == name || (name == BackupLatest && backup == "") {
+				backup = n
+			}
+		}
+		if backup == "" {
+			continue
+		}
+
+		log := c.log.With(zap.String("repo", repo.path), zap.String("action", "restore"))
+		files, err := repo.restore(ctx, backup, log)
+		if err != nil {
+			failed++
+		}
+		results = append(results, RestoreResult{Path: repo.path, Backup: backup, Files: files, Err: err})
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%w in %v of %v repositories", ErrRestoreFailed, failed, len(results))
+	}
+	if len(results) == 0 {
+		return results, ErrBackupNotFound
+	}
+	return results, nil
+}
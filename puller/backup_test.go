@@ -0,0 +1,134 @@
+package puller
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateRepoBackup(t *testing.T) {
+	tests := []struct {
+		name      string
+		dirty     bool
+		hardReset bool
+		backup    bool
+	}{
+		{"Dirty Hard Reset", true, true, true},
+		{"Clean Hard Reset", false, true, false},
+		{"Dirty Without Hard Reset", true, false, true},
+		{"Clean Without Hard Reset", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			remote := newTestRemote(t, dir, "app")
+			local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+			require.Nil(t, err)
+			before, err := local.Head()
+			require.Nil(t, err)
+
+			if tt.dirty {
+				require.Nil(t, os.WriteFile(dir+"/local/README.md", []byte("local change"), 0644))
+				require.Nil(t, os.WriteFile(dir+"/local/notes.txt", []byte("untracked"), 0644))
+				require.Nil(t, os.MkdirAll(dir+"/local/docs", os.ModePerm))
+				require.Nil(t, os.WriteFile(dir+"/local/docs/guide.md", []byte("untracked"), 0644))
+			}
+			remote.commit(t, "main.go", "package main")
+
+			r := &report{}
+			n := makeNode(&nodeOptions{
+				path:      dir + "/local",
+				log:       Log,
+				auth:      &Auth{Username: "user", Password: "pass"},
+				events:    newEventBus(r),
+				hardReset: tt.hardReset,
+			})
+			require.Nil(t, n.updateRepo(context.Background()))
+			res := r.results[0]
+			require.Equal(t, StatusSuccess, res.Status)
+
+			names, err := n.backups(context.Background())
+			require.Nil(t, err)
+			if !tt.backup {
+				require.Empty(t, res.Backup)
+				require.Empty(t, names)
+				return
+			}
+			require.Equal(t, []string{res.Backup}, names)
+
+			// Backup commit is on top of the HEAD before the update
+			ref, err := local.Reference(plumbing.ReferenceName(backupRefPrefix+res.Backup), true)
+			require.Nil(t, err)
+			commit, err := local.CommitObject(ref.Hash())
+			require.Nil(t, err)
+			require.Equal(t, []plumbing.Hash{before.Hash()}, commit.ParentHashes)
+
+			files, err := n.backupFiles(context.Background(), res.Backup)
+			require.Nil(t, err)
+			require.Equal(t, map[string][]string{"A": {"docs/guide.md", "notes.txt"}, "M": {"README.md"}}, files)
+		})
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	dir := t.TempDir()
+	remote := newTestRemote(t, dir, "app")
+	remote.commit(t, "old.txt", "old")
+	local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+	require.Nil(t, err)
+
+	require.Nil(t, os.WriteFile(dir+"/local/README.md", []byte("local change"), 0644))
+	require.Nil(t, os.WriteFile(dir+"/local/notes.txt", []byte("untracked"), 0644))
+	require.Nil(t, os.Remove(dir+"/local/old.txt"))
+	remote.commit(t, "main.go", "package main")
+
+	r := &report{}
+	n := makeNode(&nodeOptions{
+		path:      dir + "/local",
+		log:       Log,
+		auth:      &Auth{Username: "user", Password: "pass"},
+		events:    newEventBus(r),
+		hardReset: true,
+	})
+	require.Nil(t, n.updateRepo(context.Background()))
+	backup := r.results[0].Backup
+	require.NotEmpty(t, backup)
+	require.FileExists(t, dir+"/local/old.txt")
+
+	c := &engine{dir: dir, log: Log}
+	repos := []*node{n, makeNode(&nodeOptions{path: dir + "/bare/app.git", log: Log})}
+
+	list, err := c.listBackups(context.Background(), repos)
+	require.Nil(t, err)
+	require.Equal(t, []RepoBackups{{Path: dir + "/local", Backups: []BackupInfo{{Name: backup, Files: 3}}}}, list)
+
+	_, err = c.restoreBackups(context.Background(), repos, "20000101T000000.000Z")
+	require.ErrorIs(t, err, ErrBackupNotFound)
+
+	restored, err := c.restoreBackups(context.Background(), repos, BackupLatest)
+	require.Nil(t, err)
+	require.Equal(t, []RestoreResult{{Path: dir + "/local", Backup: backup, Files: 3}}, restored)
+
+	// The change is back as unstaged change on top of the pulled commit
+	content, err := os.ReadFile(dir + "/local/README.md")
+	require.Nil(t, err)
+	require.Equal(t, "local change", string(content))
+	require.FileExists(t, dir+"/local/notes.txt")
+	require.NoFileExists(t, dir+"/local/old.txt")
+	require.FileExists(t, dir+"/local/main.go")
+
+	head, err := local.Head()
+	require.Nil(t, err)
+	upstream, err := remote.work.Head()
+	require.Nil(t, err)
+	require.Equal(t, upstream.Hash(), head.Hash())
+
+	status, err := n.git(context.Background(), "status", "--porcelain")
+	require.Nil(t, err)
+	require.Equal(t, " M README.md\n D old.txt\n?? notes.txt\n", status)
+}
@@ -0,0 +1,196 @@
+package puller
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.uber.org/zap"
+)
+
+// Status of a local branch updated by the all branches option
+const (
+	BranchAdvanced = "advanced"
+	BranchUpToDate = "up-to-date"
+	BranchAhead    = "ahead"
+	BranchDiverged = "diverged"
+	BranchMerged   = "merged"
+	BranchRebased  = "rebased"
+)
+
+// BranchResult is the result of a local branch tracking a remote branch
+type BranchResult struct {
+	// Short name of the local branch
+	Name string
+
+	// Remote branch being tracked, ex: origin/master
+	Upstream string
+
+	// BranchAdvanced, BranchUpToDate, BranchAhead, BranchDiverged,
+	// or BranchMerged/BranchRebased for the reconciled checked out branch
+	Status string
+
+	// Commit of the branch before and after the update
+	OldSHA string
+	NewSHA string
+}
+
+// Fetch the remotes once then fast-forward every local branch tracking a remote
+// branch, the branch that is not checked out is only moved and never checked out.
+// The checked out branch follow the source remote like the pull does. Branch having
+// commits that are not on the remote is left untouched and reported as ahead or diverged,
+// diverged checked out branch is merged or rebased according to the divergence strategy,
+// otherwise it fail the update like the pull does.
+func (n *node) pullAllBranches(ctx context.Context, repo *git.Repository, workTree *git.Worktree, target plumbing.ReferenceName,
+	source string, auth *http.BasicAuth, backup *string, log *zap.Logger) ([]BranchResult, int, error) {
+	var (
+		attempts int
+		err      error
+	)
+	if n.allRemotes {
+		attempts, err = n.fetchRemotes(ctx, repo, auth, log, true)
+	} else {
+		var remote *git.Remote
+		remote, err = repo.Remote(source)
+		if err == nil {
+			attempts, err = n.fetchRemote(ctx, remote, auth, log, true)
+		}
+	}
+	if err != nil {
+		return nil, attempts, err
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, attempts, err
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return nil, attempts, err
+	}
+
+	results := make([]BranchResult, 0)
+	diverged := ""
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		remote, merge := source, ref.Name()
+		if ref.Name() != target {
+			tracking, ok := cfg.Branches[ref.Name().Short()]
+			if !ok || tracking.Remote == "" || tracking.Remote == "." || tracking.Merge == "" {
+				return nil
+			}
+			remote, merge = tracking.Remote, tracking.Merge
+		}
+
+		upstream, err := repo.Reference(plumbing.NewRemoteReferenceName(remote, merge.Short()), true)
+		if err != nil {
+			if ref.Name() == target {
+				return err
+			}
+			log.Debug("Remote branch not found", zap.String("branch", ref.Name().Short()), zap.String("upstream", remote+"/"+merge.Short()))
+			return nil
+		}
+
+		res, err := fastForwardBranch(repo, ref, upstream)
+		if err != nil {
+			return err
+		}
+		res.Upstream = remote + "/" + merge.Short()
+
+		// The worktree of the checked out branch follow the new commit
+		if res.Status == BranchAdvanced && ref.Name() == target {
+			err = workTree.Reset(&git.ResetOptions{Mode: git.MergeReset, Commit: upstream.Hash()})
+		} else if res.Status == BranchAdvanced {
+			err = repo.Storer.SetReference(plumbing.NewHashReference(ref.Name(), upstream.Hash()))
+		}
+		if err != nil {
+			return err
+		}
+
+		log.Debug("Branch updated", zap.String("branch", res.Name), zap.String("upstream", res.Upstream), zap.String("status", res.Status))
+		results = append(results, res)
+		if res.Status == BranchDiverged && ref.Name() == target {
+			diverged = res.Upstream
+		}
+		return nil
+	})
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	if err == nil && diverged != "" {
+		err = n.reconcileBranch(ctx, repo, target, diverged, results, backup, log)
+	}
+	return results, attempts, err
+}
+
+// Merge or rebase the diverged checked out branch then record its new commit,
+// the branch is left untouched unless the divergence strategy is set
+func (n *node) reconcileBranch(ctx context.Context, repo *git.Repository, target plumbing.ReferenceName,
+	upstream string, results []BranchResult, backup *string, log *zap.Logger) error {
+	if !n.reconcilesDivergence() {
+		return git.ErrNonFastForwardUpdate
+	}
+	if err := n.reconcile(ctx, upstream, backup, log); err != nil {
+		return err
+	}
+
+	head, err := repo.Reference(target, true)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		if results[i].Name != target.Short() {
+			continue
+		}
+		results[i].NewSHA = head.Hash().String()
+		results[i].Status = BranchMerged
+		if n.divergence == DivergenceRebase {
+			results[i].Status = BranchRebased
+		}
+	}
+	return nil
+}
+
+// Compare the local branch with its remote branch, the reference is not updated
+func fastForwardBranch(repo *git.Repository, local *plumbing.Reference, upstream *plumbing.Reference) (BranchResult, error) {
+	res := BranchResult{
+		Name:   local.Name().Short(),
+		OldSHA: local.Hash().String(),
+		NewSHA: local.Hash().String(),
+		Status: BranchUpToDate,
+	}
+	if local.Hash() == upstream.Hash() {
+		return res, nil
+	}
+
+	localCommit, err := repo.CommitObject(local.Hash())
+	if err != nil {
+		return res, err
+	}
+	upstreamCommit, err := repo.CommitObject(upstream.Hash())
+	if err != nil {
+		return res, err
+	}
+
+	behind, err := localCommit.IsAncestor(upstreamCommit)
+	if err != nil {
+		return res, err
+	}
+	if behind {
+		res.Status = BranchAdvanced
+		res.NewSHA = upstream.Hash().String()
+		return res, nil
+	}
+
+	ahead, err := upstreamCommit.IsAncestor(localCommit)
+	if err != nil {
+		return res, err
+	}
+	if ahead {
+		res.Status = BranchAhead
+	} else {
+		res.Status = BranchDiverged
+	}
+	return res, nil
+}
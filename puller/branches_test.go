@@ -0,0 +1,138 @@
+package puller
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+// Commit the file on the branch of the repository, the current branch is checked out back
+func commitOnBranch(t *testing.T, repo *git.Repository, branch string, name string) {
+	wt, err := repo.Worktree()
+	require.Nil(t, err)
+	head, err := repo.Head()
+	require.Nil(t, err)
+
+	runGit(t, wt.Filesystem.Root(), "checkout", "-q", branch)
+	commitTestFile(t, repo, name, branch)
+	runGit(t, wt.Filesystem.Root(), "checkout", "-q", head.Name().Short())
+}
+
+func TestPullAllBranches(t *testing.T) {
+	dir := t.TempDir()
+	remote := newTestRemote(t, dir, "app")
+	head, err := remote.work.Head()
+	require.Nil(t, err)
+
+	branches := []string{"feature", "stale", "ahead", "diverged", "untracked"}
+	for _, name := range branches {
+		require.Nil(t, remote.work.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), head.Hash())))
+	}
+	pushAll := func() {
+		require.Nil(t, remote.work.Push(&git.PushOptions{RemoteName: "bare", RefSpecs: []config.RefSpec{"+refs/heads/*:refs/heads/*"}}))
+	}
+	pushAll()
+
+	// Local clone having every branch tracking the remote except untracked
+	local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+	require.Nil(t, err)
+	cfg, err := local.Config()
+	require.Nil(t, err)
+	for _, name := range branches {
+		require.Nil(t, local.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), head.Hash())))
+		if name != "untracked" {
+			cfg.Branches[name] = &config.Branch{Name: name, Remote: "origin", Merge: plumbing.NewBranchReferenceName(name)}
+		}
+	}
+	require.Nil(t, local.SetConfig(cfg))
+
+	commitOnBranch(t, local, "ahead", "local.txt")
+	commitOnBranch(t, local, "diverged", "local.txt")
+	commitOnBranch(t, remote.work, "master", "master.txt")
+	commitOnBranch(t, remote.work, "feature", "feature.txt")
+	commitOnBranch(t, remote.work, "diverged", "remote.txt")
+	commitOnBranch(t, remote.work, "untracked", "remote.txt")
+	pushAll()
+
+	r := &report{}
+	n := makeNode(&nodeOptions{
+		path:        dir + "/local",
+		log:         Log,
+		auth:        &Auth{Username: "user", Password: "pass"},
+		events:      newEventBus(r),
+		allBranches: true,
+	})
+	require.Nil(t, n.updateRepo(context.Background()))
+	require.Equal(t, StatusSuccess, r.results[0].Status)
+
+	status := make(map[string]string)
+	for _, b := range r.results[0].Branches {
+		status[b.Name] = b.Status
+		require.Equal(t, "origin/"+b.Name, b.Upstream)
+	}
+	require.Equal(t, map[string]string{
+		"master":   BranchAdvanced,
+		"feature":  BranchAdvanced,
+		"stale":    BranchUpToDate,
+		"ahead":    BranchAhead,
+		"diverged": BranchDiverged,
+	}, status)
+
+	// Advanced branch point to the remote, the other one is left untouched
+	for name, want := range map[string]string{"feature": "refs/remotes/origin/feature", "untracked": "refs/heads/untracked"} {
+		ref, err := local.Reference(plumbing.NewBranchReferenceName(name), true)
+		require.Nil(t, err)
+		wantRef, err := local.Reference(plumbing.ReferenceName(want), true)
+		require.Nil(t, err)
+		require.Equal(t, wantRef.Hash(), ref.Hash())
+	}
+	untracked, _ := local.Reference(plumbing.NewBranchReferenceName("untracked"), true)
+	require.Equal(t, head.Hash(), untracked.Hash())
+
+	// Only the checked out branch update the worktree
+	localHead, err := local.Head()
+	require.Nil(t, err)
+	require.Equal(t, plumbing.Master, localHead.Name())
+	require.FileExists(t, dir+"/local/master.txt")
+	require.NoFileExists(t, dir+"/local/feature.txt")
+
+	for _, branch := range r.results[0].Branches {
+		if branch.Name == "diverged" {
+			require.Equal(t, "origin/diverged", branch.Upstream)
+		}
+	}
+
+	// Nothing changed on the next run
+	r = &report{}
+	n.events = newEventBus(r)
+	require.Nil(t, n.updateRepo(context.Background()))
+	require.Equal(t, StatusUpToDate, r.results[0].Status)
+}
+
+func TestPullAllBranchesDivergedHead(t *testing.T) {
+	dir := t.TempDir()
+	remote := newTestRemote(t, dir, "app")
+	local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+	require.Nil(t, err)
+
+	commitTestFile(t, local, "local.txt", "local")
+	remote.commit(t, "remote.txt", "remote")
+	require.Nil(t, os.WriteFile(dir+"/local/local.txt", []byte("local"), 0644))
+
+	r := &report{}
+	n := makeNode(&nodeOptions{
+		path:        dir + "/local",
+		log:         Log,
+		auth:        &Auth{Username: "user", Password: "pass"},
+		events:      newEventBus(r),
+		allBranches: true,
+	})
+	require.ErrorIs(t, n.updateRepo(context.Background()), git.ErrNonFastForwardUpdate)
+	require.Equal(t, StatusFailed, r.results[0].Status)
+	require.Equal(t, BranchDiverged, r.results[0].Branches[0].Status)
+}
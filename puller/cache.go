@@ -0,0 +1,126 @@
+package puller
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// File name of the incremental sync cache inside the state directory
+const cacheFile = "cache.json"
+
+// syncCache remember the gitlab activity and the local HEAD of every repository
+// from the previous run, so the repository that has not been changed on both
+// side can be skipped by the incremental update.
+type syncCache struct {
+	mu      sync.Mutex
+	root    string
+	path    string
+	log     *zap.Logger
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+type cacheEntry struct {
+	LastActivityAt time.Time `json:"last_activity_at"`
+	Head           string    `json:"head"`
+}
+
+// Load the cache inside root directory. Missing or corrupted cache
+// is treated as empty, so every repository is updated.
+func loadCache(root string, log *zap.Logger) *syncCache {
+	s := &syncCache{
+		root:    root,
+		path:    root + "/" + stateDir + "/" + cacheFile,
+		log:     log,
+		entries: make(map[string]cacheEntry),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return s
+	}
+
+	if err = json.Unmarshal(data, &s.entries); err != nil {
+		log.Warn("Cache is corrupted, ignore it", zap.String("path", s.path), zap.Error(err))
+		s.entries = make(map[string]cacheEntry)
+	}
+	return s
+}
+
+// Check the repository has no activity on gitlab and its local HEAD
+// is still the same since the last time it was synced
+func (s *syncCache) unchanged(repo gitlabRepo) bool {
+	if repo.project.LastActivityAt == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	entry, ok := s.entries[relativePath(s.root, repo.path())]
+	s.mu.Unlock()
+	if !ok || !entry.LastActivityAt.Equal(*repo.project.LastActivityAt) {
+		return false
+	}
+
+	head, err := localHead(repo.path())
+	return err == nil && head == entry.Head
+}
+
+// Remember the activity and the local HEAD of the synced repository
+func (s *syncCache) record(repo gitlabRepo) {
+	if repo.project.LastActivityAt == nil {
+		return
+	}
+
+	head, err := localHead(repo.path())
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[relativePath(s.root, repo.path())] = cacheEntry{
+		LastActivityAt: *repo.project.LastActivityAt,
+		Head:           head,
+	}
+	s.dirty = true
+}
+
+// Persist the cache when there is any repository recorded
+func (s *syncCache) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		s.log.Warn("Failed to encode cache", zap.Error(err))
+		return
+	}
+
+	if err = writeState(s.root, s.path, data); err != nil {
+		s.log.Warn("Failed to write cache", zap.String("path", s.path), zap.Error(err))
+		return
+	}
+	s.dirty = false
+}
+
+// Return hash of the HEAD commit of local repository
+func localHead(path string) (string, error) {
+	repo, err := openRepo(path)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
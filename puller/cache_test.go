@@ -0,0 +1,69 @@
+package puller
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Create a repository with a single commit on the given path
+func initTestRepo(t *testing.T, path string) *git.Repository {
+	repo, err := git.PlainInit(path, false)
+	require.Nil(t, err)
+	commitTestFile(t, repo, "README.md", "init")
+	return repo
+}
+
+// Write the file inside worktree and commit it
+func commitTestFile(t *testing.T, repo *git.Repository, name, content string) {
+	wt, err := repo.Worktree()
+	require.Nil(t, err)
+
+	require.Nil(t, os.WriteFile(wt.Filesystem.Root()+"/"+name, []byte(content), 0644))
+	_, err = wt.Add(name)
+	require.Nil(t, err)
+
+	_, err = wt.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "tester", Email: "tester@example.com", When: time.Now()},
+	})
+	require.Nil(t, err)
+}
+
+func TestSyncCache(t *testing.T) {
+	root := t.TempDir()
+	repo := initTestRepo(t, root+"/Api")
+
+	activity := time.Date(2022, 4, 1, 10, 0, 0, 0, time.UTC)
+	project := gitlabRepo{dir: root, project: &gitlab.Project{Name: "Api", LastActivityAt: &activity}}
+
+	cache := loadCache(root, Log)
+	require.False(t, cache.unchanged(project))
+
+	cache.record(project)
+	cache.save()
+
+	t.Run("Unchanged", func(t *testing.T) {
+		require.True(t, loadCache(root, Log).unchanged(project))
+	})
+
+	t.Run("ServerActivity", func(t *testing.T) {
+		newActivity := activity.Add(time.Hour)
+		changed := gitlabRepo{dir: root, project: &gitlab.Project{Name: "Api", LastActivityAt: &newActivity}}
+		require.False(t, loadCache(root, Log).unchanged(changed))
+	})
+
+	t.Run("NoActivityInfo", func(t *testing.T) {
+		unknown := gitlabRepo{dir: root, project: &gitlab.Project{Name: "Api"}}
+		require.False(t, loadCache(root, Log).unchanged(unknown))
+	})
+
+	t.Run("LocalCommit", func(t *testing.T) {
+		commitTestFile(t, repo, "main.go", "package main")
+		require.False(t, loadCache(root, Log).unchanged(project))
+	})
+}
@@ -0,0 +1,249 @@
+package puller
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"go.uber.org/zap"
+)
+
+// Ignore file read from every walked directory, using the gitignore syntax.
+// Its patterns apply to the directory of the file and below.
+const ignoreFileName = ".pullerignore"
+
+// Directory never walked unless it's negated by an ignore file (ex: !vendor/),
+// they can be huge and don't contain repository being synced
+var defaultIgnorePatterns = []gitignore.Pattern{
+	gitignore.ParsePattern("node_modules/", nil),
+	gitignore.ParsePattern("vendor/", nil),
+}
+
+// Search for repository inside the node directory and visit every one of them.
+// Repository is detected by its .git entry (directory, or file of linked worktree and submodule)
+// or by the layout of a bare repository, nothing is opened. Bare repository has no worktree to be
+// updated, so it's skipped. Repository is never walked into, so nested worktree and submodule is not visited twice. Directory matching the ignore
+// patterns or the excluded group is not walked, excluded project is not visited.
+// With max depth set, directory deeper than the max depth below the node is not walked.
+func (n *node) walkRepos(ctx context.Context, visit func(*node)) error {
+	return n.walk(ctx, nil, defaultIgnorePatterns, visit)
+}
+
+// Directory waiting to be walked, rel is its path relative to the root being walked
+// and patterns are the ignore patterns applied to it
+type walkDir struct {
+	node     *node
+	rel      []string
+	patterns []gitignore.Pattern
+}
+
+// Walk the directories one by one, in the order of their name
+func (n *node) walk(ctx context.Context, rel []string, patterns []gitignore.Pattern, visit func(*node)) error {
+	dirs, err := n.readWalkDir(rel, patterns, visit)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		// Stop scheduling new repository when the run is canceled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err = dir.node.walk(ctx, dir.rel, dir.patterns, visit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Same as walkRepos but the directories are read by up to the given number of goroutines,
+// so visit must be safe for concurrent use and the repositories are visited in no particular order.
+// Unreadable directory doesn't stop the other one from being walked, the first error is returned.
+func (n *node) walkReposParallel(ctx context.Context, workers int, visit func(*node)) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, workers)
+	)
+
+	var walk func(dir walkDir)
+	walk = func(dir walkDir) {
+		defer wg.Done()
+
+		dirs, err := dir.node.readWalkDir(dir.rel, dir.patterns, visit)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			return
+		}
+
+		// Subdirectory is walked by a new goroutine while there's a free one,
+		// otherwise by the current goroutine so the walk never wait for itself
+		for _, sub := range dirs {
+			wg.Add(1)
+			select {
+			case sem <- struct{}{}:
+				go func(sub walkDir) {
+					defer func() { <-sem }()
+					walk(sub)
+				}(sub)
+			default:
+				walk(sub)
+			}
+		}
+	}
+
+	wg.Add(1)
+	walk(walkDir{node: n, patterns: defaultIgnorePatterns})
+	wg.Wait()
+	return firstErr
+}
+
+// Read the node directory: repository is visited, otherwise its subdirectories that
+// are not ignored nor excluded are returned to be walked, unless the max depth is reached
+func (n *node) readWalkDir(rel []string, patterns []gitignore.Pattern, visit func(*node)) ([]walkDir, error) {
+	entries, err := os.ReadDir(n.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if repo, bare := isRepoEntries(entries); repo {
+		// Bare repository has no worktree to be updated, it's neither visited nor walked into
+		if bare {
+			n.log.Debug("Skip bare repository", zap.String("path", n.path))
+			return nil, nil
+		}
+		if _, ok := n.exProjects[n.name]; !ok {
+			n.events.discovered(n.path)
+			visit(n)
+		}
+		return nil, nil
+	}
+
+	if n.maxDepth > 0 && len(rel) >= n.maxDepth {
+		n.log.Debug("Skip directory deeper than max depth", zap.String("path", n.path))
+		return nil, nil
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == ignoreFileName && !entry.IsDir() {
+			patterns = append(append([]gitignore.Pattern(nil), patterns...), readIgnoreFile(n.path, rel, n.log)...)
+		}
+	}
+	ignore := gitignore.NewMatcher(patterns)
+
+	var dirs []walkDir
+	for _, entry := range entries {
+
+		if !entry.IsDir() {
+			continue
+		}
+
+		dirPath := n.path + "/" + entry.Name()
+		dirRel := append(append([]string(nil), rel...), entry.Name())
+		if ignore.Match(dirRel, true) {
+			n.log.Debug("Skip ignored directory", zap.String("path", dirPath))
+			continue
+		}
+		if _, ok := n.exGroups[entry.Name()]; ok && !isRepo(dirPath) {
+			n.log.Debug("Skip excluded directory", zap.String("path", dirPath))
+			continue
+		}
+		n.log.Debug("Scanning directory", zap.String("path", dirPath))
+
+		dirs = append(dirs, walkDir{node: n.child(dirPath), rel: dirRel, patterns: patterns})
+	}
+	return dirs, nil
+}
+
+// Read the patterns of the ignore file inside the directory, unreadable file is ignored
+func readIgnoreFile(dir string, rel []string, log *zap.Logger) []gitignore.Pattern {
+	file, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if err != nil {
+		log.Warn("Failed to read ignore file", zap.String("path", dir), zap.Error(err))
+		return nil
+	}
+	defer file.Close()
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, rel))
+	}
+	return patterns
+}
+
+// Check whether the directory entries are the one of a repository: a .git entry
+// or the HEAD file, objects and refs directory of a bare repository
+func isRepoEntries(entries []os.DirEntry) (repo bool, bare bool) {
+	layout := 0
+	for _, entry := range entries {
+		switch {
+		case entry.Name() == git.GitDirName:
+			return true, false
+		case entry.Name() == "HEAD" && !entry.IsDir(),
+			(entry.Name() == "objects" || entry.Name() == "refs") && entry.IsDir():
+			layout++
+		}
+	}
+	return layout == 3, layout == 3
+}
+
+// Checking current given directory is a git repository
+// by its .git entry or its bare repository layout
+func isRepo(path string) bool {
+	if _, err := os.Lstat(filepath.Join(path, git.GitDirName)); err == nil {
+		return true
+	}
+
+	head, err := os.Stat(filepath.Join(path, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+	for _, dir := range []string{"objects", "refs"} {
+		if info, err := os.Stat(filepath.Join(path, dir)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// Open the repository, the .git file of linked worktree
+// pointing to the common directory of its main repository is followed
+func openRepo(path string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+}
+
+// Check whether the repository is a linked worktree (git worktree add),
+// its .git is a file and its git directory has the commondir file
+func isLinkedWorktree(path string) bool {
+	content, err := os.ReadFile(filepath.Join(path, git.GitDirName))
+	if err != nil || !strings.HasPrefix(string(content), "gitdir: ") {
+		return false
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir: "))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(path, gitDir)
+	}
+	_, err = os.Stat(filepath.Join(gitDir, "commondir"))
+	return err == nil
+}
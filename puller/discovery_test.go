@@ -0,0 +1,224 @@
+package puller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWalkRepos(t *testing.T) {
+	root := t.TempDir()
+	initTestRepo(t, root+"/team/api")
+	initTestRepo(t, root+"/team/api/nested")
+	initTestRepo(t, root+"/node_modules/pkg")
+	initTestRepo(t, root+"/vendor/lib")
+	initTestRepo(t, root+"/tools/vendor/lib")
+	initTestRepo(t, root+"/archive/old")
+	initTestRepo(t, root+"/deep/a/b/c")
+	_, err := git.PlainInit(root+"/mirror.git", true)
+	require.Nil(t, err)
+	initTestRepo(t, root+"/mirror.git/hooks/inner")
+	runGit(t, root+"/team/api", "worktree", "add", "-q", "-b", "feature", root+"/worktrees/api-feature")
+
+	require.Nil(t, os.WriteFile(root+"/.pullerignore", []byte("# archived projects\narchive/\n\n!tools/vendor/\n"), 0644))
+	require.Nil(t, os.WriteFile(root+"/deep/.pullerignore", []byte("c\n"), 0644))
+
+	// Bare repository has no worktree, it's skipped and not walked into
+	tests := []struct {
+		name     string
+		maxDepth int
+		want     []string
+	}{
+		{"No Limit", 0, []string{"team/api", "tools/vendor/lib", "worktrees/api-feature"}},
+		{"Max Depth", 2, []string{"team/api", "worktrees/api-feature"}},
+		{"Root Only", 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := makeNode(&nodeOptions{
+				path:     root,
+				log:      Log,
+				maxDepth: tt.maxDepth,
+			})
+
+			var repos []string
+			err := n.walkRepos(context.Background(), func(repo *node) {
+				repos = append(repos, repo.path[len(root)+1:])
+			})
+			require.Nil(t, err)
+			require.Equal(t, tt.want, repos)
+
+			// Concurrent walk find the same repositories in any order
+			mu := sync.Mutex{}
+			repos = nil
+			err = n.walkReposParallel(context.Background(), 4, func(repo *node) {
+				mu.Lock()
+				defer mu.Unlock()
+				repos = append(repos, repo.path[len(root)+1:])
+			})
+			require.Nil(t, err)
+			sort.Strings(repos)
+			require.Equal(t, tt.want, repos)
+		})
+	}
+}
+
+func TestWalkReposParallelError(t *testing.T) {
+	root := t.TempDir()
+	err := makeNode(&nodeOptions{path: root + "/missing", log: Log}).walkReposParallel(context.Background(), 4, func(*node) {})
+	require.True(t, os.IsNotExist(err))
+
+	initTestRepo(t, root+"/api")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = makeNode(&nodeOptions{path: root, log: Log}).walkReposParallel(ctx, 4, func(*node) {})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestUpdateProjectParallel(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"web", "api", "cli", "docs", "infra", "mobile"}
+	for i, name := range names {
+		remote := newTestRemote(t, dir, name)
+		_, err := git.PlainClone(fmt.Sprintf("%v/local/group%v/%v", dir, i%2, name), false, &git.CloneOptions{URL: remote.url})
+		require.Nil(t, err)
+		remote.commit(t, "main.go", "package main")
+	}
+
+	r := &report{}
+	err := makeNode(&nodeOptions{
+		path:   dir + "/local",
+		log:    Log,
+		auth:   &Auth{Username: "user", Password: "pass"},
+		events: newEventBus(r),
+	}).updateProject(context.Background(), 3)
+	require.Nil(t, err)
+	require.Len(t, r.results, len(names))
+	for _, res := range r.results {
+		require.Equal(t, StatusSuccess, res.Status, res.Path)
+	}
+
+	// The results are listed in the order of the path, whatever order the workers finished
+	results := r.list()
+	require.True(t, sort.SliceIsSorted(results, func(i, j int) bool { return results[i].Path < results[j].Path }))
+}
+
+// Create a workspace of groups, subgroups and repositories along with node_modules
+// directories full of packages, repository is only a .git directory as nothing is opened
+func newBenchmarkTree(b *testing.B, groups, subgroups, repos int) string {
+	root := b.TempDir()
+	for g := 0; g < groups; g++ {
+		for s := 0; s < subgroups; s++ {
+			for r := 0; r < repos; r++ {
+				repo := fmt.Sprintf("%v/group%v/sub%v/repo%v", root, g, s, r)
+				require.Nil(b, os.MkdirAll(repo+"/.git", os.ModePerm))
+				require.Nil(b, os.MkdirAll(repo+"/src/pkg", os.ModePerm))
+			}
+			for p := 0; p < 20; p++ {
+				require.Nil(b, os.MkdirAll(fmt.Sprintf("%v/group%v/sub%v/web/node_modules/pkg%v/lib", root, g, s, p), os.ModePerm))
+			}
+		}
+	}
+	return root
+}
+
+func BenchmarkWalkRepos(b *testing.B) {
+	root := newBenchmarkTree(b, 10, 10, 10)
+	n := makeNode(&nodeOptions{path: root, log: zap.NewNop()})
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			count := 0
+			require.Nil(b, n.walkRepos(context.Background(), func(*node) { count++ }))
+			require.Equal(b, 1000, count)
+		}
+	})
+
+	for _, workers := range []int{4, 16} {
+		b.Run(fmt.Sprintf("Parallel %v", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var count int64
+				require.Nil(b, n.walkReposParallel(context.Background(), workers, func(*node) { atomic.AddInt64(&count, 1) }))
+				require.Equal(b, int64(1000), count)
+			}
+		})
+	}
+}
+
+func TestIsRepo(t *testing.T) {
+	root := t.TempDir()
+	initTestRepo(t, root+"/repo")
+	_, err := git.PlainInit(root+"/bare.git", true)
+	require.Nil(t, err)
+	runGit(t, root+"/repo", "worktree", "add", "-q", "-b", "feature", root+"/worktree")
+	require.Nil(t, os.MkdirAll(root+"/plain/objects", os.ModePerm))
+
+	tests := []struct {
+		name     string
+		path     string
+		repo     bool
+		worktree bool
+	}{
+		{"Repository", root + "/repo", true, false},
+		{"Bare", root + "/bare.git", true, false},
+		{"Linked Worktree", root + "/worktree", true, true},
+		{"Plain Directory", root + "/plain", false, false},
+		{"Missing", root + "/missing", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.repo, isRepo(tt.path))
+			require.Equal(t, tt.worktree, isLinkedWorktree(tt.path))
+		})
+	}
+}
+
+func TestUpdateLinkedWorktree(t *testing.T) {
+	dir := t.TempDir()
+	remote := newTestRemote(t, dir, "app")
+	head, err := remote.work.Head()
+	require.Nil(t, err)
+	require.Nil(t, remote.work.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("feature"), head.Hash())))
+	require.Nil(t, remote.work.Push(&git.PushOptions{RemoteName: "bare", RefSpecs: []config.RefSpec{"refs/heads/feature:refs/heads/feature"}}))
+
+	local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+	require.Nil(t, err)
+	runGit(t, dir+"/local", "worktree", "add", "-q", dir+"/feature", "feature")
+
+	commitOnBranch(t, remote.work, "feature", "feature.txt")
+	require.Nil(t, remote.work.Push(&git.PushOptions{RemoteName: "bare", RefSpecs: []config.RefSpec{"refs/heads/feature:refs/heads/feature"}}))
+	upstream, err := remote.work.Reference(plumbing.NewBranchReferenceName("feature"), true)
+	require.Nil(t, err)
+
+	r := &report{}
+	n := makeNode(&nodeOptions{
+		path:   dir + "/feature",
+		log:    Log,
+		auth:   &Auth{Username: "user", Password: "pass"},
+		events: newEventBus(r),
+	})
+	require.Nil(t, n.updateRepo(context.Background()))
+	require.Equal(t, StatusSuccess, r.results[0].Status)
+	require.FileExists(t, dir+"/feature/feature.txt")
+
+	// The branch is updated on the common repository, the main worktree stay on master
+	feature, err := local.Reference(plumbing.NewBranchReferenceName("feature"), true)
+	require.Nil(t, err)
+	require.Equal(t, upstream.Hash(), feature.Hash())
+	localHead, err := local.Head()
+	require.Nil(t, err)
+	require.Equal(t, plumbing.Master, localHead.Name())
+	require.Equal(t, head.Hash(), localHead.Hash())
+}
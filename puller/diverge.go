@@ -0,0 +1,193 @@
+package puller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Strategy applied on the checked out branch diverged from its remote branch
+const (
+	DivergenceSkip   = "skip"
+	DivergenceMerge  = "merge"
+	DivergenceRebase = "rebase"
+)
+
+// Time given to abort the merge or the rebase, on its own as the repository context may be done
+const abortTimeout = 30 * time.Second
+
+var (
+	ErrDivergenceNotValid = errors.New("Divergence strategy not valid, use skip, merge or rebase")
+	ErrConflict           = errors.New("Diverged branch has conflict")
+)
+
+// ConflictError is returned when the merge or the rebase of the diverged branch
+// has conflict, the branch is restored as it was before the merge or the rebase
+type ConflictError struct {
+	// DivergenceMerge or DivergenceRebase
+	Strategy string
+
+	// Remote branch being merged or rebased onto, ex: origin/master
+	Upstream string
+
+	// Files having conflict, relative to the repository
+	Files []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v onto %v has conflict in %v", e.Strategy, e.Upstream, strings.Join(e.Files, ", "))
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Check whether the strategy is known, empty strategy is the same as skip
+func validDivergence(strategy string) bool {
+	switch strategy {
+	case "", DivergenceSkip, DivergenceMerge, DivergenceRebase:
+		return true
+	}
+	return false
+}
+
+// Check whether the diverged branch is merged or rebased instead of being left untouched
+func (n *node) reconcilesDivergence() bool {
+	return n.divergence == DivergenceMerge || n.divergence == DivergenceRebase
+}
+
+// Merge the upstream into the checked out branch or rebase the local commits on top of it.
+// go-git can only fast-forward, so the git binary is used. On conflict the merge or the rebase
+// is aborted and the conflicting files are returned as ConflictError. The rebase rewrite the local
+// commits, so they're backed up first unless the uncommitted change backup already keep them.
+func (n *node) reconcile(ctx context.Context, upstream string, backup *string, log *zap.Logger) error {
+	n.events.phase(n.path, ActionUpdate, PhaseReconcile)
+
+	args := []string{"merge", "--no-edit", upstream}
+	if n.divergence == DivergenceRebase {
+		args = []string{"rebase", upstream}
+		if *backup == "" {
+			n.events.phase(n.path, ActionUpdate, PhaseBackup)
+			name, err := n.backup(log, true)
+			if err != nil {
+				return err
+			}
+			*backup = name
+		}
+	}
+
+	// Commit and branch the merge or the rebase start from, restored when it can't be aborted
+	head, err := n.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("%v onto %v: %w: %v", n.divergence, upstream, err, strings.TrimSpace(head))
+	}
+	branch, _ := n.git(ctx, "symbolic-ref", "-q", "HEAD")
+
+	out, err := n.git(ctx, args...)
+	if err == nil {
+		log.Debug("Diverged branch reconciled", zap.String("strategy", n.divergence), zap.String("upstream", upstream))
+		return nil
+	}
+
+	// The merge or the rebase may have been killed by the repository timeout or the interruption
+	// of the run, so it's aborted using its own context. The index lock and the temporary
+	// files of the merge driver left by the killed git are removed.
+	abortCtx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	if ctx.Err() != nil {
+		n.removeIndexLock(abortCtx, log)
+		n.removeMergeFiles(log)
+	}
+
+	// Unmerged files are only listed while the merge or the rebase is in progress
+	var conflicts []string
+	if files, err := n.git(abortCtx, "diff", "--name-only", "-z", "--diff-filter=U"); err == nil {
+		for _, file := range strings.Split(files, "\x00") {
+			if file != "" {
+				conflicts = append(conflicts, file)
+			}
+		}
+	}
+	if abortOut, abortErr := n.git(abortCtx, args[0], "--abort"); abortErr != nil {
+		// Killed before its state (MERGE_HEAD, rebase-merge) is written, there is nothing to abort
+		log.Debug("Failed to abort, resetting instead", zap.String("strategy", n.divergence),
+			zap.String("output", strings.TrimSpace(abortOut)))
+		if err := n.resetReconcile(abortCtx, strings.TrimSpace(head), strings.TrimSpace(branch)); err != nil {
+			log.Warn("Failed to reset the killed merge or rebase", zap.String("strategy", n.divergence), zap.Error(err))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &ConflictError{Strategy: n.divergence, Upstream: upstream, Files: conflicts}
+	}
+	return fmt.Errorf("%v onto %v: %w: %v", n.divergence, upstream, err, strings.TrimSpace(out))
+}
+
+// Put HEAD, the index and the worktree back on the commit the merge or the rebase started from,
+// keeping the uncommitted change it didn't touch (reset --merge). The rebase may have
+// detached HEAD already, so the branch is checked out again.
+func (n *node) resetReconcile(ctx context.Context, head string, branch string) error {
+	if out, err := n.git(ctx, "reset", "--merge", head); err != nil {
+		return fmt.Errorf("reset --merge %v: %w: %v", head, err, strings.TrimSpace(out))
+	}
+	if branch == "" {
+		return nil
+	}
+	if out, err := n.git(ctx, "symbolic-ref", "HEAD", branch); err != nil {
+		return fmt.Errorf("symbolic-ref %v: %w: %v", branch, err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// Remove the index lock left by the git process killed while holding it
+func (n *node) removeIndexLock(ctx context.Context, log *zap.Logger) {
+	out, err := n.git(ctx, "rev-parse", "--git-path", "index.lock")
+	if err != nil {
+		return
+	}
+
+	lock := strings.TrimSpace(out)
+	if !filepath.IsAbs(lock) {
+		lock = filepath.Join(n.path, lock)
+	}
+	if err := os.Remove(lock); err == nil {
+		log.Debug("Removed index lock of the killed git", zap.String("lock", lock))
+	}
+}
+
+// Remove the temporary files (.merge_file_XXXXXX) given to the merge driver of the killed git
+func (n *node) removeMergeFiles(log *zap.Logger) {
+	files, _ := filepath.Glob(filepath.Join(n.path, ".merge_file_*"))
+	for _, file := range files {
+		if err := os.Remove(file); err == nil {
+			log.Debug("Removed merge file of the killed git", zap.String("file", file))
+		}
+	}
+}
+
+// Run git command inside the repository, the combined output is returned
+func (n *node) git(ctx context.Context, args ...string) (string, error) {
+	return n.gitEnv(ctx, nil, args...)
+}
+
+// Run git command inside the repository with additional environment variables
+func (n *node) gitEnv(ctx context.Context, env []string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = n.path
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.String(), err
+}
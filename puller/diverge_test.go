@@ -0,0 +1,219 @@
+package puller
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateRepoDivergence(t *testing.T) {
+	tests := []struct {
+		name        string
+		divergence  string
+		allBranches bool
+		conflict    bool
+		err         error
+		parents     int
+		branch      string
+	}{
+		{"Skip", "", false, false, git.ErrNonFastForwardUpdate, 1, ""},
+		{"Merge", DivergenceMerge, false, false, nil, 2, ""},
+		{"Rebase", DivergenceRebase, false, false, nil, 1, ""},
+		{"Merge Conflict", DivergenceMerge, false, true, ErrConflict, 1, ""},
+		{"Rebase Conflict", DivergenceRebase, false, true, ErrConflict, 1, ""},
+		{"All Branches Skip", DivergenceSkip, true, false, git.ErrNonFastForwardUpdate, 1, BranchDiverged},
+		{"All Branches Merge", DivergenceMerge, true, false, nil, 2, BranchMerged},
+		{"All Branches Rebase", DivergenceRebase, true, false, nil, 1, BranchRebased},
+		{"All Branches Conflict", DivergenceRebase, true, true, ErrConflict, 1, BranchDiverged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			remote := newTestRemote(t, dir, "app")
+			local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+			require.Nil(t, err)
+			runGit(t, dir+"/local", "config", "user.name", "tester")
+			runGit(t, dir+"/local", "config", "user.email", "tester@example.com")
+
+			localFile, remoteFile := "local.txt", "remote.txt"
+			if tt.conflict {
+				localFile, remoteFile = "conflict file.txt", "conflict file.txt"
+			}
+			commitTestFile(t, local, localFile, "local")
+			remote.commit(t, remoteFile, "remote")
+
+			before, err := local.Head()
+			require.Nil(t, err)
+			upstream, err := remote.work.Head()
+			require.Nil(t, err)
+
+			r := &report{}
+			n := makeNode(&nodeOptions{
+				path:        dir + "/local",
+				log:         Log,
+				auth:        &Auth{Username: "user", Password: "pass"},
+				events:      newEventBus(r),
+				allBranches: tt.allBranches,
+				divergence:  tt.divergence,
+			})
+			err = n.updateRepo(context.Background())
+			res := r.results[0]
+			if tt.branch != "" {
+				require.Equal(t, tt.branch, res.Branches[0].Status)
+			}
+
+			head, headErr := local.Head()
+			require.Nil(t, headErr)
+			commit, headErr := local.CommitObject(head.Hash())
+			require.Nil(t, headErr)
+			require.Equal(t, tt.parents, commit.NumParents())
+
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				require.Equal(t, StatusFailed, res.Status)
+				require.Equal(t, before.Hash(), head.Hash())
+				require.NoFileExists(t, dir+"/local/.git/MERGE_HEAD")
+				require.NoDirExists(t, dir+"/local/.git/rebase-merge")
+				if tt.conflict {
+					require.Equal(t, []string{"conflict file.txt"}, res.Conflicts)
+					content, err := os.ReadFile(dir + "/local/conflict file.txt")
+					require.Nil(t, err)
+					require.Equal(t, "local", string(content))
+				}
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, StatusSuccess, res.Status)
+			require.FileExists(t, dir+"/local/local.txt")
+			require.FileExists(t, dir+"/local/remote.txt")
+			if tt.divergence != DivergenceRebase {
+				require.Empty(t, res.Backup)
+				return
+			}
+			require.Equal(t, []plumbing.Hash{upstream.Hash()}, commit.ParentHashes)
+
+			// The worktree was clean, the backup keep the local commit the rebase rewrote
+			ref, err := local.Reference(plumbing.ReferenceName(backupRefPrefix+res.Backup), true)
+			require.Nil(t, err)
+			backup, err := local.CommitObject(ref.Hash())
+			require.Nil(t, err)
+			require.Equal(t, []plumbing.Hash{before.Hash()}, backup.ParentHashes)
+		})
+	}
+}
+
+// Merge killed before it write MERGE_HEAD can't be aborted, it's reset instead
+func TestResetKilledMerge(t *testing.T) {
+	dir := t.TempDir()
+	remote := newTestRemote(t, dir, "app")
+	local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+	require.Nil(t, err)
+	commitTestFile(t, local, "conflict.txt", "local")
+	before, err := local.Head()
+	require.Nil(t, err)
+
+	// Worktree and index left half merged by the killed git, without MERGE_HEAD
+	require.Nil(t, os.WriteFile(dir+"/local/conflict.txt", []byte("half merged"), 0644))
+	require.Nil(t, os.WriteFile(dir+"/local/added.txt", []byte("from remote"), 0644))
+	runGit(t, dir+"/local", "add", "conflict.txt", "added.txt")
+
+	n := makeNode(&nodeOptions{path: dir + "/local", log: Log})
+	_, err = n.git(context.Background(), "merge", "--abort")
+	require.NotNil(t, err)
+	require.Nil(t, n.resetReconcile(context.Background(), before.Hash().String(), "refs/heads/master"))
+
+	content, err := os.ReadFile(dir + "/local/conflict.txt")
+	require.Nil(t, err)
+	require.Equal(t, "local", string(content))
+	require.NoFileExists(t, dir+"/local/added.txt")
+	status, err := n.git(context.Background(), "status", "--porcelain")
+	require.Nil(t, err)
+	require.Equal(t, "", status)
+}
+
+func TestUpdateRepoDivergenceTimeout(t *testing.T) {
+	for _, strategy := range []string{DivergenceMerge, DivergenceRebase} {
+		t.Run(strategy, func(t *testing.T) {
+			dir := t.TempDir()
+			remote := newTestRemote(t, dir, "app")
+			local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: remote.url})
+			require.Nil(t, err)
+			runGit(t, dir+"/local", "config", "user.name", "tester")
+			runGit(t, dir+"/local", "config", "user.email", "tester@example.com")
+
+			// The merge driver outlast the repository timeout, so git is killed in the middle
+			runGit(t, dir+"/local", "config", "merge.slow.driver", "exec sleep 3 </dev/null >/dev/null 2>&1")
+			require.Nil(t, os.MkdirAll(dir+"/local/.git/info", os.ModePerm))
+			require.Nil(t, os.WriteFile(dir+"/local/.git/info/attributes", []byte("*.txt merge=slow\n"), 0644))
+
+			commitTestFile(t, local, "conflict.txt", "local")
+			remote.commit(t, "conflict.txt", "remote")
+			before, err := local.Head()
+			require.Nil(t, err)
+
+			r := &report{}
+			n := makeNode(&nodeOptions{
+				path:        dir + "/local",
+				log:         Log,
+				auth:        &Auth{Username: "user", Password: "pass"},
+				events:      newEventBus(r),
+				divergence:  strategy,
+				repoTimeout: time.Second,
+			})
+			require.ErrorIs(t, n.updateRepo(context.Background()), ErrRepoTimeout)
+
+			// The killed merge or rebase is aborted, the branch is left as it was
+			head, err := local.Head()
+			require.Nil(t, err)
+			require.Equal(t, before.Hash(), head.Hash())
+			require.NoFileExists(t, dir+"/local/.git/index.lock")
+			require.NoFileExists(t, dir+"/local/.git/MERGE_HEAD")
+			require.NoDirExists(t, dir+"/local/.git/rebase-merge")
+			require.Equal(t, plumbing.NewBranchReferenceName("master"), head.Name())
+
+			// The worktree and the index are restored as well
+			content, err := os.ReadFile(dir + "/local/conflict.txt")
+			require.Nil(t, err)
+			require.Equal(t, "local", string(content))
+			status, err := n.git(context.Background(), "status", "--porcelain")
+			require.Nil(t, err)
+			require.Equal(t, "", status)
+		})
+	}
+}
+
+func TestValidDivergence(t *testing.T) {
+	for _, strategy := range []string{"", DivergenceSkip, DivergenceMerge, DivergenceRebase} {
+		require.True(t, validDivergence(strategy))
+	}
+	require.False(t, validDivergence("squash"))
+}
+
+func TestValidateGitBinary(t *testing.T) {
+	auth := &Auth{Username: "user", Password: "pass"}
+	tests := []struct {
+		name string
+		opt  Options
+		err  error
+	}{
+		{"Skip", Options{Action: "update", Dir: ".", Auth: auth, Logs: Log}, nil},
+		{"Merge", Options{Action: "update", Dir: ".", Auth: auth, Logs: Log, Divergence: DivergenceMerge}, ErrGitNotFound},
+		{"Rebase", Options{Action: "update", Dir: ".", Auth: auth, Logs: Log, Divergence: DivergenceRebase}, ErrGitNotFound},
+		{"Restore", Options{Action: "restore", Dir: ".", Logs: Log}, ErrGitNotFound},
+	}
+
+	// Nothing is found without PATH, the update itself doesn't need git
+	t.Setenv("PATH", "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, validate(&tt.opt), tt.err)
+		})
+	}
+}
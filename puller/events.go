@@ -0,0 +1,340 @@
+package puller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EventType is the kind of event emitted while syncing
+type EventType string
+
+const (
+	// Repository is found while walking the local directory or the gitlab tree
+	EventRepoDiscovered EventType = "repo-discovered"
+
+	// Every repository has been found, Count is the number of repositories
+	EventDiscoveryFinished EventType = "discovery-finished"
+
+	// Repository start being cloned or updated
+	EventCloneStarted  EventType = "clone-started"
+	EventUpdateStarted EventType = "update-started"
+
+	// Gitlab group is being listed, Path is the directory of the group
+	EventDiscoveryProgress EventType = "discovery-progress"
+
+	// Phase of the running clone/update is changed (Message is empty)
+	// or the remote send transfer progress (Message is set)
+	EventCloneProgress  EventType = "clone-progress"
+	EventUpdateProgress EventType = "update-progress"
+
+	// Repository is finished, the result is attached to the event
+	EventCloneFinished  EventType = "clone-finished"
+	EventUpdateFinished EventType = "update-finished"
+
+	// Failure that doesn't belong to a repository result, ex: failed gitlab request
+	EventError EventType = "error"
+)
+
+// Phase of the running clone or update, set on EventCloneProgress and EventUpdateProgress.
+// PhaseEnumerate is only set on EventDiscoveryProgress.
+const (
+	PhaseEnumerate = "enumerate"
+	PhaseClone     = "clone"
+	PhaseFetch     = "fetch"
+	PhaseCheckout  = "checkout"
+	PhaseBackup    = "backup"
+	PhaseReconcile = "reconcile"
+	PhasePush      = "push"
+	PhaseSubmodule = "submodule"
+	PhaseLFS       = "lfs"
+)
+
+// Sideband message of the remote, ex: "Receiving objects:  45% (123/270)"
+var sidebandProgress = regexp.MustCompile(`([A-Za-z ]+):\s+(\d+)% \((\d+)/(\d+)\)`)
+
+// Event emitted while syncing, only the field related to the type is set
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Local path of the repository
+	Path string
+
+	// ActionClone or ActionUpdate, set on the started, progress and finished events
+	Action string
+
+	// Phase of the operation, one of the Phase constants,
+	// and the progress message sent by the remote
+	Phase   string
+	Message string
+
+	// Number of repositories found, only set on EventDiscoveryFinished
+	Count int
+
+	// Result of the repository, only set on the finished event
+	Result *RepoResult
+
+	// Failure of EventError
+	Err error
+}
+
+// Observer receive every event of the run. It's called by the concurrent
+// workers, so the implementation must be safe for concurrent use.
+type Observer interface {
+	OnEvent(Event)
+}
+
+// ObserverFunc use a function as an observer
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// eventBus deliver the event to every observer of the run.
+// Every method is safe on a nil bus, so node without observer still can be used.
+type eventBus struct {
+	observers []Observer
+}
+
+func newEventBus(observers ...Observer) *eventBus {
+	b := &eventBus{}
+	for _, o := range observers {
+		if o != nil {
+			b.observers = append(b.observers, o)
+		}
+	}
+	return b
+}
+
+func (b *eventBus) emit(e Event) {
+	if b == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for _, o := range b.observers {
+		o.OnEvent(e)
+	}
+}
+
+func (b *eventBus) discovered(path string) {
+	b.emit(Event{Type: EventRepoDiscovered, Path: path})
+}
+
+func (b *eventBus) discoveryFinished(count int) {
+	b.emit(Event{Type: EventDiscoveryFinished, Count: count})
+}
+
+func (b *eventBus) started(path string, action string) {
+	typ := EventUpdateStarted
+	if action == ActionClone {
+		typ = EventCloneStarted
+	}
+	b.emit(Event{Type: typ, Path: path, Action: action})
+}
+
+func (b *eventBus) enumerating(path string) {
+	b.emit(Event{Type: EventDiscoveryProgress, Path: path, Phase: PhaseEnumerate})
+}
+
+func (b *eventBus) phase(path string, action string, phase string) {
+	b.emit(Event{Type: progressType(action), Path: path, Action: action, Phase: phase})
+}
+
+func (b *eventBus) finished(res RepoResult) {
+	typ := EventUpdateFinished
+	if res.Action == ActionClone {
+		typ = EventCloneFinished
+	}
+	b.emit(Event{Type: typ, Path: res.Path, Action: res.Action, Result: &res})
+}
+
+func (b *eventBus) error(path string, err error) {
+	b.emit(Event{Type: EventError, Path: path, Err: err})
+}
+
+// Writer receiving the sideband progress of go-git for the phase of the action,
+// nil when there is no observer so go-git doesn't ask for it
+func (b *eventBus) progressWriter(path string, action string, phase string) io.Writer {
+	if b == nil || len(b.observers) == 0 {
+		return nil
+	}
+	return &sidebandWriter{events: b, path: path, action: action, phase: phase}
+}
+
+// Progress event of the clone or the update
+func progressType(action string) EventType {
+	if action == ActionClone {
+		return EventCloneProgress
+	}
+	return EventUpdateProgress
+}
+
+// sidebandWriter parse the sideband progress into the progress event of the action. Message is
+// separated by carriage return or new line, only complete message is emitted.
+type sidebandWriter struct {
+	events *eventBus
+	path   string
+	action string
+	phase  string
+	buf    []byte
+}
+
+func (w *sidebandWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := strings.IndexAny(string(w.buf), "\r\n")
+		if i < 0 {
+			break
+		}
+
+		msg := strings.TrimSpace(strings.TrimPrefix(string(w.buf[:i]), "remote:"))
+		w.buf = w.buf[i+1:]
+		if msg == "" {
+			continue
+		}
+
+		if m := sidebandProgress.FindStringSubmatch(msg); m != nil {
+			msg = fmt.Sprintf("%v %v%% (%v/%v)", strings.TrimSpace(m[1]), m[2], m[3], m[4])
+		}
+		w.events.emit(Event{Type: progressType(w.action), Path: w.path, Action: w.action, Phase: w.phase, Message: msg})
+	}
+	return len(b), nil
+}
+
+// logObserver log the result of every repository and every error
+type logObserver struct {
+	log *zap.Logger
+}
+
+func (o *logObserver) OnEvent(e Event) {
+	switch e.Type {
+	case EventCloneFinished, EventUpdateFinished:
+		res := e.Result
+		fields := []zap.Field{zap.String("repo", res.Path), zap.String("action", res.Action), zap.String("status", res.Status),
+			zap.Int("attempts", res.Attempts), zap.Duration("duration", res.Duration)}
+		if res.Status == StatusFailed {
+			o.log.Error("Failed to sync repository", append(fields, zap.Error(res.Err))...)
+			return
+		}
+		o.log.Debug("Finish syncing repository", fields...)
+	case EventError:
+		o.log.Error("Sync error", zap.String("repo", e.Path), zap.Error(e.Err))
+	}
+}
+
+// jsonObserver write every event as a line of JSON
+type jsonObserver struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// Create observer writing every event as a line of JSON into the writer,
+// progress of the transfer is not written
+func NewJSONObserver(w io.Writer) Observer {
+	return &jsonObserver{enc: json.NewEncoder(w)}
+}
+
+// JSON form of the event, error is written as its message
+type jsonEvent struct {
+	Type     EventType  `json:"type"`
+	Time     time.Time  `json:"time"`
+	Path     string     `json:"path,omitempty"`
+	Action   string     `json:"action,omitempty"`
+	Phase    string     `json:"phase,omitempty"`
+	Count    int        `json:"count,omitempty"`
+	Status   string     `json:"status,omitempty"`
+	Attempts int        `json:"attempts,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Hooks    []jsonHook `json:"hooks,omitempty"`
+
+	Submodules []jsonSubmodule `json:"submodules,omitempty"`
+	LFS        *jsonLFS        `json:"lfs,omitempty"`
+	Branches   []jsonBranch    `json:"branches,omitempty"`
+	Conflicts  []string        `json:"conflicts,omitempty"`
+	Backup     string          `json:"backup,omitempty"`
+}
+
+type jsonBranch struct {
+	Name     string `json:"name"`
+	Upstream string `json:"upstream"`
+	Status   string `json:"status"`
+	OldSHA   string `json:"old_sha"`
+	NewSHA   string `json:"new_sha"`
+}
+
+type jsonLFS struct {
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+type jsonSubmodule struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+}
+
+type jsonHook struct {
+	Name   string `json:"name"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (o *jsonObserver) OnEvent(e Event) {
+	if (e.Type == EventCloneProgress || e.Type == EventUpdateProgress) && e.Message != "" {
+		return
+	}
+
+	out := jsonEvent{
+		Type:   e.Type,
+		Time:   e.Time,
+		Path:   e.Path,
+		Action: e.Action,
+		Phase:  e.Phase,
+		Count:  e.Count,
+		Error:  errorString(e.Err),
+	}
+
+	if res := e.Result; res != nil {
+		out.Status = res.Status
+		out.Attempts = res.Attempts
+		out.Duration = res.Duration.String()
+		out.Error = errorString(res.Err)
+		for _, hook := range res.Hooks {
+			out.Hooks = append(out.Hooks, jsonHook{Name: hook.Name, Output: hook.Output, Error: errorString(hook.Err)})
+		}
+		for _, sub := range res.Submodules {
+			out.Submodules = append(out.Submodules, jsonSubmodule{Path: sub.Path, Error: errorString(sub.Err)})
+		}
+		for _, b := range res.Branches {
+			out.Branches = append(out.Branches, jsonBranch{Name: b.Name, Upstream: b.Upstream, Status: b.Status, OldSHA: b.OldSHA, NewSHA: b.NewSHA})
+		}
+		out.Conflicts = res.Conflicts
+		out.Backup = res.Backup
+		if res.LFS != nil {
+			out.LFS = &jsonLFS{Files: res.LFS.Files, Bytes: res.LFS.Bytes, Error: errorString(res.LFS.Err)}
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_ = o.enc.Encode(out)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,150 @@
+package puller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recorder keep every event emitted, used for asserting the events
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) OnEvent(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// Return the events of the repository path
+func (r *recorder) repo(path string) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]Event, 0)
+	for _, e := range r.events {
+		if e.Path == path {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestSidebandWriter(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Input    []string
+		Messages []string
+	}{
+		{
+			Name:     "Percentage",
+			Input:    []string{"Counting objects:  33% (1/3)\r", "Counting objects: 100% (3/3), done.\n"},
+			Messages: []string{"Counting objects 33% (1/3)", "Counting objects 100% (3/3)"},
+		},
+		{
+			Name:     "RemotePrefix",
+			Input:    []string{"remote: Enumerating objects: 5, done.\n"},
+			Messages: []string{"Enumerating objects: 5, done."},
+		},
+		{
+			Name:     "SplitMessage",
+			Input:    []string{"Receiving objects:  4", "5% (123/270)\r", "Receiving"},
+			Messages: []string{"Receiving objects 45% (123/270)"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			r := &recorder{}
+			w := newEventBus(r).progressWriter("repo", ActionClone, PhaseClone)
+			for _, in := range test.Input {
+				n, err := w.Write([]byte(in))
+				require.Nil(t, err)
+				require.Equal(t, len(in), n)
+			}
+
+			messages := make([]string, 0)
+			for _, e := range r.events {
+				require.Equal(t, EventCloneProgress, e.Type)
+				require.Equal(t, ActionClone, e.Action)
+				require.Equal(t, PhaseClone, e.Phase)
+				messages = append(messages, e.Message)
+			}
+			require.Equal(t, test.Messages, messages)
+		})
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	var nilBus *eventBus
+	require.Nil(t, nilBus.progressWriter("repo", ActionClone, PhaseClone))
+	require.Nil(t, newEventBus().progressWriter("repo", ActionClone, PhaseClone))
+	nilBus.started("repo", ActionClone)
+
+	r := &recorder{}
+	bus := newEventBus(r, nil)
+	require.Len(t, bus.observers, 1)
+
+	bus.started("repo", ActionClone)
+	bus.finished(RepoResult{Path: "repo", Action: ActionUpdate, Status: StatusSuccess})
+	require.Equal(t, EventCloneStarted, r.events[0].Type)
+	require.Equal(t, EventUpdateFinished, r.events[1].Type)
+	require.Equal(t, StatusSuccess, r.events[1].Result.Status)
+	require.False(t, r.events[1].Time.IsZero())
+
+	// Progress of the clone and of the update can be told apart
+	bus.phase("repo", ActionClone, PhaseCheckout)
+	bus.phase("repo", ActionUpdate, PhaseFetch)
+	bus.enumerating("group")
+	require.Equal(t, Event{Type: EventCloneProgress, Path: "repo", Action: ActionClone, Phase: PhaseCheckout}, withoutTime(r.events[2]))
+	require.Equal(t, Event{Type: EventUpdateProgress, Path: "repo", Action: ActionUpdate, Phase: PhaseFetch}, withoutTime(r.events[3]))
+	require.Equal(t, Event{Type: EventDiscoveryProgress, Path: "group", Phase: PhaseEnumerate}, withoutTime(r.events[4]))
+}
+
+// Event without its time, so it can be compared
+func withoutTime(e Event) Event {
+	e.Time = time.Time{}
+	return e
+}
+
+func TestJSONObserver(t *testing.T) {
+	out := &bytes.Buffer{}
+	bus := newEventBus(NewJSONObserver(out))
+
+	bus.discoveryFinished(3)
+	_, _ = bus.progressWriter("repo", ActionUpdate, PhaseFetch).Write([]byte("Counting objects:  33% (1/3)\r"))
+	bus.phase("repo", ActionUpdate, PhaseCheckout)
+	bus.finished(RepoResult{
+		Path:   "repo",
+		Action: ActionUpdate,
+		Status: StatusFailed,
+		Err:    errors.New("connection reset"),
+		Hooks:  []HookResult{{Name: hookPreUpdate, Output: "ok\n"}},
+	})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var events []map[string]interface{}
+	for _, line := range lines {
+		e := make(map[string]interface{})
+		require.Nil(t, json.Unmarshal([]byte(line), &e))
+		delete(e, "time")
+		events = append(events, e)
+	}
+
+	require.Equal(t, map[string]interface{}{"type": "discovery-finished", "count": float64(3)}, events[0])
+	require.Equal(t, map[string]interface{}{"type": "update-progress", "path": "repo", "action": "update", "phase": "checkout"}, events[1])
+	require.Equal(t, "update-finished", events[2]["type"])
+	require.Equal(t, "failed", events[2]["status"])
+	require.Equal(t, "connection reset", events[2]["error"])
+	require.Equal(t, []interface{}{map[string]interface{}{"name": "pre-update", "output": "ok\n"}}, events[2]["hooks"])
+}
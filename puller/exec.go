@@ -0,0 +1,198 @@
+package puller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrExecArgsNotSet = errors.New("Command for exec action has not been set")
+	ErrExecFailed     = errors.New("Command failed")
+)
+
+// ExecResult is the result of the command run inside a repository
+type ExecResult struct {
+	// Local path of the repository
+	Path string
+
+	// Exit code of the command, -1 when the command can't be started or was killed
+	ExitCode int
+
+	// Nil when the command exit successfully
+	Err error
+
+	// Time spent running the command
+	Duration time.Duration
+}
+
+// prefixWriter write every complete line of the output with the prefix,
+// writers of every repository share the lock so the lines are never mixed
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+// Run the command inside every repository found in the directory, the output is prefixed
+// with the repository path and written into out. The result of every repository is returned.
+func (c *engine) exec(ctx context.Context, out io.Writer) ([]ExecResult, error) {
+	if len(c.execArgs) == 0 {
+		return nil, ErrExecArgsNotSet
+	}
+
+	start := time.Now()
+	c.log.Debug("Start executing command", zap.String("action", "exec"), zap.Strings("args", c.execArgs))
+
+	root := makeNode(&nodeOptions{
+		path:       c.dir,
+		exGroups:   c.exGroups,
+		exProjects: c.exProjects,
+		maxDepth:   c.maxDepth,
+		log:        c.log,
+	})
+
+	var repos []string
+	err := root.walkRepos(ctx, func(repo *node) {
+		repos = append(repos, repo.path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := c.execRepos(ctx, repos, out)
+	c.log.Debug("Finish executing command", zap.String("action", "exec"), zap.Int("repos", len(results)),
+		zap.Duration("duration", time.Since(start)))
+
+	failed := 0
+	for _, res := range results {
+		if res.ExitCode != 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%w in %v of %v repositories", ErrExecFailed, failed, len(results))
+	}
+	return results, nil
+}
+
+// Run the command inside the repositories using a pool of workers.
+// Result is returned in the same order as the given repositories.
+func (c *engine) execRepos(ctx context.Context, repos []string, out io.Writer) []ExecResult {
+	workers := c.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]ExecResult, len(repos))
+	jobs := make(chan int)
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.execRepo(ctx, repos[i], out, mu)
+			}
+		}()
+	}
+
+	for i := range repos {
+		// Stop scheduling new repository when the run is canceled
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Repository that never started is reported as canceled
+	for i := range results {
+		if results[i].Path == "" {
+			results[i] = ExecResult{Path: repos[i], ExitCode: -1, Err: ctx.Err()}
+		}
+	}
+	return results
+}
+
+// Run the command inside a single repository
+func (c *engine) execRepo(ctx context.Context, path string, out io.Writer, mu *sync.Mutex) ExecResult {
+	start := time.Now()
+	prefix := "[" + strings.TrimPrefix(strings.TrimPrefix(path, c.dir), "/") + "] "
+	if path == c.dir {
+		prefix = "[.] "
+	}
+
+	stdout := &prefixWriter{mu: mu, out: out, prefix: prefix}
+	stderr := &prefixWriter{mu: mu, out: out, prefix: prefix}
+
+	cmd := exec.CommandContext(ctx, c.execArgs[0], c.execArgs[1:]...)
+	cmd.Dir = path
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	stdout.flush()
+	stderr.flush()
+
+	res := ExecResult{
+		Path:     path,
+		Err:      err,
+		Duration: time.Since(start),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		res.ExitCode = 0
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	default:
+		res.ExitCode = -1
+	}
+
+	c.log.Debug("Command finished", zap.String("repo", path), zap.String("action", "exec"),
+		zap.Int("exit_code", res.ExitCode), zap.Duration("duration", res.Duration))
+	return res
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		p.writeLine(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Write the remaining output that doesn't end with new line
+func (p *prefixWriter) flush() {
+	if len(p.buf) == 0 {
+		return
+	}
+
+	p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = io.WriteString(p.out, p.prefix+string(line))
+}
@@ -0,0 +1,76 @@
+package puller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRepos(t *testing.T) {
+	root := t.TempDir()
+	initTestRepo(t, root+"/group/first")
+	initTestRepo(t, root+"/group/second")
+	initTestRepo(t, root+"/vendor/third")
+	initTestRepo(t, root+"/excluded")
+	require.Nil(t, os.MkdirAll(root+"/empty", os.ModePerm))
+
+	c := &engine{
+		dir:        root,
+		workers:    2,
+		log:        Log,
+		exGroups:   map[string]struct{}{"vendor": {}},
+		exProjects: map[string]struct{}{"excluded": {}},
+		execArgs:   []string{"sh", "-c", `echo "$PWD"; printf partial; test "${PWD##*/}" != second`},
+	}
+
+	var repos []string
+	err := makeNode(&nodeOptions{
+		path:       c.dir,
+		exGroups:   c.exGroups,
+		exProjects: c.exProjects,
+		log:        Log,
+	}).walkRepos(context.Background(), func(repo *node) {
+		repos = append(repos, repo.path)
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{root + "/group/first", root + "/group/second"}, repos)
+
+	out := &bytes.Buffer{}
+	results := c.execRepos(context.Background(), repos, out)
+	require.Len(t, results, 2)
+	require.Equal(t, 0, results[0].ExitCode)
+	require.Equal(t, 1, results[1].ExitCode)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	sort.Strings(lines)
+	require.Equal(t, []string{
+		"[group/first] " + root + "/group/first",
+		"[group/first] partial",
+		"[group/second] " + root + "/group/second",
+		"[group/second] partial",
+	}, lines)
+
+	results, err = c.exec(context.Background(), &bytes.Buffer{})
+	require.True(t, errors.Is(err, ErrExecFailed))
+	require.Len(t, results, 2)
+	require.Equal(t, root+"/group/second", results[1].Path)
+}
+
+func TestExecCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &engine{workers: 1, log: Log, execArgs: []string{"true"}}
+	results := c.execRepos(ctx, []string{"first", "second"}, &bytes.Buffer{})
+	require.Len(t, results, 2)
+	for _, res := range results {
+		require.Equal(t, -1, res.ExitCode)
+		require.True(t, errors.Is(res.Err, context.Canceled))
+	}
+}
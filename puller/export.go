@@ -0,0 +1,310 @@
+package puller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Export the repositories found inside the directory
+	ExportLocal = "local"
+
+	// Export the projects of the gitlab group tree
+	ExportGitlab = "gitlab"
+)
+
+var ErrExportFromNotValid = errors.New("Export source must be local or gitlab")
+
+func validExportFrom(from string) bool {
+	return from == "" || from == ExportLocal || from == ExportGitlab
+}
+
+// Build the manifest from the source of the export, in the order the repositories are found
+func (c *engine) exportManifest(ctx context.Context) (*Manifest, error) {
+	if c.exportFrom == ExportGitlab {
+		return c.exportGitlab(ctx)
+	}
+	return c.exportLocal(ctx)
+}
+
+// Every repository inside the directory with the url of its origin, its checked out
+// branch and the commit of HEAD. Repository without origin can't be reproduced, it's skipped.
+func (c *engine) exportLocal(ctx context.Context) (*Manifest, error) {
+	root := makeNode(&nodeOptions{
+		path:       c.dir,
+		exGroups:   c.exGroups,
+		exProjects: c.exProjects,
+		maxDepth:   c.maxDepth,
+		log:        c.log,
+	})
+
+	manifest := &Manifest{Repos: make([]ManifestRepo, 0)}
+	err := root.walkRepos(ctx, func(n *node) {
+		url := remoteURL(n.path)
+		if url == "" {
+			c.log.Warn("Repository has no origin remote, it's not exported", zap.String("repo", n.path))
+			return
+		}
+
+		repo := ManifestRepo{
+			Path: strings.TrimPrefix(n.path, c.dir+"/"),
+			URL:  exportURL(url),
+		}
+		repo.Branch, repo.Commit = localBranch(n.path)
+		if repo.Branch == "" && repo.Commit != "" {
+			c.log.Warn("Commit of the detached HEAD is not on any branch of origin, it can't be reproduced",
+				zap.String("repo", n.path), zap.String("commit", repo.Commit))
+		}
+		manifest.Repos = append(manifest.Repos, repo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Remove the credential from the url, so the manifest can be shared. The whole userinfo
+// (ex: user:token@) of http url is removed, other url only lose its password.
+// Url that can't be parsed, like the scp-like syntax (git@host:path), is kept as it is.
+func exportURL(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+
+	if u.Scheme == "http" || u.Scheme == "https" {
+		u.User = nil
+	} else if _, ok := u.User.Password(); ok {
+		u.User = url.User(u.User.Username())
+	} else {
+		return remote
+	}
+	return u.String()
+}
+
+// Return the branch checked out by the repository and the commit of HEAD. Detached HEAD
+// get the branch of origin containing its commit, so the commit is cloned along with the branch.
+// The branch is empty when no branch contain the commit, and the commit when the branch has no commit yet.
+func localBranch(path string) (string, string) {
+	repo, err := openRepo(path)
+	if err != nil {
+		return "", ""
+	}
+
+	branch := ""
+	if head, err := repo.Reference(plumbing.HEAD, false); err == nil && head.Target().IsBranch() {
+		branch = head.Target().Short()
+	}
+
+	commit := ""
+	if head, err := repo.Head(); err == nil {
+		commit = head.Hash().String()
+		if branch == "" {
+			branch = containingBranch(repo, head.Hash())
+		}
+	}
+	return branch, commit
+}
+
+// Return the branch of origin containing the commit, the default branch of origin
+// is checked first then the other one by name. Empty when no branch contain the commit.
+func containingBranch(repo *git.Repository, hash plumbing.Hash) string {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return ""
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return ""
+	}
+	prefix := "refs/remotes/" + git.DefaultRemoteName + "/"
+	var names []plumbing.ReferenceName
+	_ = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && strings.HasPrefix(ref.Name().String(), prefix) {
+			names = append(names, ref.Name())
+		}
+		return nil
+	})
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	if head, err := repo.Reference(plumbing.NewRemoteHEADReferenceName(git.DefaultRemoteName), false); err == nil {
+		names = append([]plumbing.ReferenceName{head.Target()}, names...)
+	}
+
+	for _, name := range names {
+		tip, err := repo.Reference(name, true)
+		if err != nil {
+			continue
+		}
+		tipCommit, err := repo.CommitObject(tip.Hash())
+		if err != nil {
+			continue
+		}
+		// Shallow history can't be walked entirely, the branch is then not known to contain the commit
+		if ok, err := commit.IsAncestor(tipCommit); err == nil && ok {
+			return strings.TrimPrefix(name.String(), prefix)
+		}
+	}
+	return ""
+}
+
+// Every project of the gitlab tree with its url, its default branch and the commit the branch
+// point to on the server. Only the ref advertisement of the remote is read, nothing is cloned.
+func (c *engine) exportGitlab(ctx context.Context) (*Manifest, error) {
+	client, err := c.newGitlabClient()
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := c.enumerateGitlab(ctx, client, true)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := c.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		exported = make([]ManifestRepo, len(repos))
+		mu       sync.Mutex
+		firstErr error
+		jobs     = make(chan int)
+		wg       = &sync.WaitGroup{}
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				repo, err := c.exportProject(ctx, repos[i])
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				exported[i] = repo
+			}
+		}()
+	}
+
+	for i := range repos {
+		// Stop listing the remotes when the run is canceled
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return &Manifest{Repos: exported}, nil
+}
+
+// Read the branch and its commit of the gitlab project from its remote (ls-remote).
+// The default branch of the project is used, or the branch HEAD of the remote point to.
+func (c *engine) exportProject(ctx context.Context, repo gitlabRepo) (ManifestRepo, error) {
+	exported := ManifestRepo{
+		Path:   strings.TrimPrefix(repo.path(), c.dir+"/"),
+		URL:    repo.project.HTTPURLToRepo,
+		Branch: repo.project.DefaultBranch,
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{exported.URL},
+	})
+	auth := &http.BasicAuth{
+		Username: c.auth.Username,
+		Password: c.auth.Password,
+	}
+
+	var refs []*plumbing.Reference
+	log := c.log.With(zap.String("repo", repo.path()), zap.String("action", "ls-remote"))
+	_, err := c.retry.do(ctx, log, "ls-remote", func() error {
+		var err error
+		refs, err = remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+		return err
+	})
+	// Project without any commit is exported with its default branch only
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return exported, nil
+	}
+	if err != nil {
+		return exported, fmt.Errorf("Repo %v: %w", exported.Path, err)
+	}
+
+	hashes := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference && exported.Branch == "" {
+			exported.Branch = ref.Target().Short()
+		}
+		if ref.Type() == plumbing.HashReference {
+			hashes[ref.Name()] = ref.Hash()
+		}
+	}
+
+	if hash, ok := hashes[plumbing.NewBranchReferenceName(exported.Branch)]; ok {
+		exported.Commit = hash.String()
+	}
+	return exported, nil
+}
+
+// WriteManifest write the manifest as JSON when the file has .json extension, as YAML otherwise.
+// The manifest is written into w when the file is empty.
+func WriteManifest(m *Manifest, file string, w io.Writer) error {
+	var (
+		content []byte
+		err     error
+	)
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		content, err = json.MarshalIndent(m, "", "  ")
+		content = append(content, '\n')
+	} else {
+		buf := &strings.Builder{}
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(2)
+		if err = enc.Encode(m); err == nil {
+			err = enc.Close()
+		}
+		content = []byte(buf.String())
+	}
+	if err != nil {
+		return err
+	}
+
+	if file == "" {
+		_, err = w.Write(content)
+		return err
+	}
+	return os.WriteFile(file, content, 0644)
+}
@@ -0,0 +1,502 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.uber.org/zap"
+)
+
+// go-git fail to fetch into a shallow repository, ex: cloned with --depth
+var ErrShallowRepo = errors.New("Shallow repository can't be updated, remove it to be cloned again with full history")
+
+type node struct {
+	name       string
+	path       string
+	exGroups   map[string]struct{}
+	exProjects map[string]struct{}
+	maxDepth   int
+	hardReset  bool
+	events     *eventBus
+	log        *zap.Logger
+	auth       *Auth
+	retry      RetryPolicy
+	hooks      Hooks
+	submodules bool
+	lfs        bool
+
+	allRemotes  bool
+	pushOrigin  bool
+	allBranches bool
+	divergence  string
+
+	// branch and remote declared by the manifest, empty use the default
+	branch string
+	remote string
+
+	repoTimeout time.Duration
+}
+
+type nodeOptions struct {
+	// Define the root path of the action
+	path string
+
+	// Set directory (group) and repository (project) name being skipped
+	exGroups   map[string]struct{}
+	exProjects map[string]struct{}
+
+	// Set how deep the directory is walked looking for repository, zero means no limit
+	maxDepth int
+
+	// Set if the hard reset need to be done
+	hardReset bool
+
+	// Set the event bus delivering the progress and the result to the observers of the run
+	events *eventBus
+
+	// Set the default logger for the node
+	log *zap.Logger
+
+	// Set the default auth method (username/password or with token)
+	auth *Auth
+
+	// Set the retry policy for the network operation
+	retry RetryPolicy
+
+	// Set the time limit of a single repository update
+	repoTimeout time.Duration
+
+	// Set the commands run inside the repository before and after the update
+	hooks Hooks
+
+	// Set if the submodules need to be updated after the pull
+	submodules bool
+
+	// Set if the LFS objects need to be fetched after the pull
+	lfs bool
+
+	// Set if every remote is fetched and the branch is pulled from upstream,
+	// then pushed into origin
+	allRemotes bool
+	pushOrigin bool
+
+	// Set if every local branch tracking a remote branch is fast-forwarded
+	allBranches bool
+
+	// Set the strategy applied on the checked out branch diverged from its remote branch
+	divergence string
+
+	// Set the branch being updated and the remote it's pulled from,
+	// empty means the default branch and origin
+	branch string
+	remote string
+}
+
+// Start updating git folder from the given root directory.
+// The update was doing recursive function for every node folder inside given directory
+func (c *engine) UpdateGit(ctx context.Context) error {
+	c.startRun()
+
+	// Start the working tree of update
+	node := makeNode(&nodeOptions{
+		path:       c.dir,
+		exGroups:   c.exGroups,
+		exProjects: c.exProjects,
+		maxDepth:   c.maxDepth,
+		hardReset:  c.hardReset,
+		events:     c.events,
+		log:        c.log,
+		auth:       c.auth,
+		retry:      c.retry,
+		hooks:      c.hooks,
+		submodules: c.submodules,
+		lfs:        c.lfs,
+
+		allRemotes:  c.allRemotes,
+		pushOrigin:  c.pushOrigin,
+		allBranches: c.allBranches,
+		divergence:  c.divergence,
+
+		repoTimeout: c.repoTimeout,
+	})
+
+	err := node.updateProject(ctx, c.workers)
+	if err != nil {
+		return err
+	}
+
+	c.log.Debug("Finish updating project", zap.String("action", ActionUpdate))
+	return nil
+}
+
+// Create node for every folder being accessed.
+// This will help to do better saving data about path and dir name
+func makeNode(opt *nodeOptions) *node {
+	arrPath := strings.Split(opt.path, "/")
+	node := node{
+		path:       opt.path,
+		name:       arrPath[len(arrPath)-1],
+		exGroups:   opt.exGroups,
+		exProjects: opt.exProjects,
+		maxDepth:   opt.maxDepth,
+		hardReset:  opt.hardReset,
+		events:     opt.events,
+		log:        opt.log,
+		auth:       opt.auth,
+		retry:      opt.retry,
+		hooks:      opt.hooks,
+		submodules: opt.submodules,
+		lfs:        opt.lfs,
+
+		allRemotes:  opt.allRemotes,
+		pushOrigin:  opt.pushOrigin,
+		allBranches: opt.allBranches,
+		divergence:  opt.divergence,
+		branch:      opt.branch,
+		remote:      opt.remote,
+
+		repoTimeout: opt.repoTimeout,
+	}
+	return &node
+}
+
+// Update every git repository found inside the node directory using a pool of workers,
+// fed by the concurrent walk as soon as the repository is found.
+// Failure is emitted as the result and the other repository is still updated.
+func (n *node) updateProject(ctx context.Context, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	repos := make(chan *node)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range repos {
+				_ = repo.updateRepo(ctx)
+			}
+		}()
+	}
+
+	err := n.walkReposParallel(ctx, workers, func(repo *node) {
+		repos <- repo
+	})
+	close(repos)
+	wg.Wait()
+	return err
+}
+
+// Create node for the directory inside the node, sharing every option of its parent
+func (n *node) child(path string) *node {
+	node := *n
+	node.path = path
+	node.name = filepath.Base(path)
+	return &node
+}
+
+// Update git repository on master branch
+// do git fetch all, restore anything that change and do git pull on master branch.
+// The pre-update and post-update hook is run around the update, failed hook
+// is recorded in the result without failing the update, so does failed submodule and LFS object.
+// Conflicting files of the diverged branch are recorded in the failed result.
+// The uncommitted change is backed up before anything is reset or checked out.
+func (n *node) updateRepo(ctx context.Context) error {
+	if !isRepo(n.path) || ctx.Err() != nil {
+		return nil
+	}
+
+	start := time.Now()
+	n.events.started(n.path, ActionUpdate)
+
+	var (
+		hooks []HookResult
+		env   hookEnv
+	)
+	if !n.hooks.empty() {
+		env = hookEnv{path: n.path, action: ActionUpdate, url: remoteURL(n.path)}
+		env.oldSHA, _ = localHead(n.path)
+		hooks = appendHook(hooks, runHook(ctx, n.log, hookPreUpdate, n.hooks.PreUpdate, env))
+	}
+
+	repoCtx, cancel := withRepoTimeout(ctx, n.repoTimeout)
+	defer cancel()
+
+	status, attempts, branches, backup, err := n.pullRepo(repoCtx)
+	err = repoTimeoutError(ctx, repoCtx, n.repoTimeout, err)
+	if err != nil {
+		var conflict *ConflictError
+		var conflicts []string
+		if errors.As(err, &conflict) {
+			conflicts = conflict.Files
+		}
+
+		n.events.finished(RepoResult{
+			Path:      n.path,
+			Action:    ActionUpdate,
+			Status:    StatusFailed,
+			Attempts:  attempts,
+			Err:       err,
+			Hooks:     hooks,
+			Branches:  branches,
+			Conflicts: conflicts,
+			Backup:    backup,
+			Duration:  time.Since(start),
+		})
+		return err
+	}
+
+	var subs []SubmoduleResult
+	if n.submodules {
+		n.events.phase(n.path, ActionUpdate, PhaseSubmodule)
+		subs = updateSubmodules(repoCtx, n.log, n.retry, n.path, basicAuth(n.auth))
+	}
+
+	var lfs *LFSResult
+	if n.lfs {
+		n.events.phase(n.path, ActionUpdate, PhaseLFS)
+		lfs = fetchLFS(repoCtx, n.log, n.retry, n.path, n.auth)
+	}
+
+	if !n.hooks.empty() {
+		env.newSHA, _ = localHead(n.path)
+		hooks = appendHook(hooks, runHook(ctx, n.log, hookPostUpdate, n.hooks.PostUpdate, env))
+	}
+
+	n.events.finished(RepoResult{
+		Path:       n.path,
+		Action:     ActionUpdate,
+		Status:     status,
+		Attempts:   attempts,
+		Hooks:      hooks,
+		Submodules: subs,
+		LFS:        lfs,
+		Branches:   branches,
+		Backup:     backup,
+		Duration:   time.Since(start),
+	})
+	return nil
+}
+
+// Reset the worktree, checkout master branch and pull it from the remote.
+// With all remotes option every remote is fetched and the branch is pulled from
+// upstream when the repository has it, then optionally pushed into origin.
+// With all branches option every local branch tracking a remote branch is fast-forwarded.
+// Diverged branch is merged or rebased according to the divergence strategy.
+// Pull is retried using the node retry policy, the status and the number of attempt is returned.
+// When the remote branch is already the same as the local one, the worktree is left untouched.
+// Every phase and the transfer progress is emitted as progress event.
+func (n *node) pullRepo(ctx context.Context) (string, int, []BranchResult, string, error) {
+	var err error
+	auth := basicAuth(n.auth)
+
+	start := time.Now()
+	log := n.log.With(zap.String("repo", n.path), zap.String("action", ActionUpdate))
+
+	repo, err := openRepo(n.path)
+	if err != nil {
+		return StatusFailed, 0, nil, "", err
+	}
+	log.Debug("Updating repository")
+
+	// With every remote or branch being fetched, the shortcut of origin can't tell the repository is up-to-date
+	branch := targetBranch(repo, n.path)
+	if n.branch != "" {
+		branch = plumbing.NewBranchReferenceName(n.branch)
+	}
+
+	source := git.DefaultRemoteName
+	if n.remote != "" {
+		source = n.remote
+	}
+
+	if !n.hardReset && !n.allRemotes && !n.allBranches && n.isUpToDate(ctx, repo, branch, source, auth) {
+		log.Debug("Repository is up-to-date", zap.Duration("duration", time.Since(start)))
+		return StatusUpToDate, 1, nil, "", nil
+	}
+
+	// Upstream on another host than origin is pulled anonymously, like it's fetched
+	pullAuth := auth
+	if n.allRemotes {
+		source = sourceRemote(repo)
+		if remote, err := repo.Remote(source); err == nil {
+			pullAuth = remoteAuth(remoteURL(n.path), remote, auth)
+		}
+	}
+
+	gitPullOption := git.PullOptions{
+		RemoteName:    source,
+		ReferenceName: branch,
+		SingleBranch:  true,
+		Progress:      n.events.progressWriter(n.path, ActionUpdate, PhaseFetch),
+	}
+	if pullAuth != nil {
+		gitPullOption.Auth = pullAuth
+	}
+
+	// The reset and the forced checkout below discard the uncommitted change,
+	// so it's backed up first (nothing when it's clean)
+	n.events.phase(n.path, ActionUpdate, PhaseBackup)
+	backup, err := n.backup(log, false)
+	if err != nil {
+		return StatusFailed, 0, nil, "", err
+	}
+
+	n.events.phase(n.path, ActionUpdate, PhaseCheckout)
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return StatusFailed, 0, nil, backup, err
+	}
+
+	if n.hardReset {
+		_ = workTree.Reset(&git.ResetOptions{Mode: git.HardReset})
+	} else {
+		_ = workTree.Reset(&git.ResetOptions{Mode: git.SoftReset})
+	}
+
+	_ = workTree.AddWithOptions(&git.AddOptions{All: true})
+	err = workTree.Checkout(&git.CheckoutOptions{
+		Force:  true,
+		Keep:   true,
+		Branch: branch,
+	})
+	if err != nil {
+		return StatusFailed, 0, nil, backup, err
+	}
+
+	n.events.phase(n.path, ActionUpdate, PhaseFetch)
+	if n.allBranches {
+		branches, attempts, err := n.pullAllBranches(ctx, repo, workTree, branch, source, auth, &backup, log)
+		if err != nil {
+			return StatusFailed, attempts, branches, backup, err
+		}
+
+		status := StatusUpToDate
+		for _, b := range branches {
+			if b.Status == BranchAdvanced || b.Status == BranchMerged || b.Status == BranchRebased {
+				status = StatusSuccess
+			}
+		}
+		if err := n.pushUpstream(ctx, repo, branch, source, auth, log); err != nil {
+			return StatusFailed, attempts, branches, backup, err
+		}
+		log.Debug("Finish updating repository", zap.Int("branches", len(branches)), zap.String("remote", source), zap.Int("attempts", attempts),
+			zap.Duration("duration", time.Since(start)))
+		return status, attempts, branches, backup, nil
+	}
+
+	if n.allRemotes {
+		attempts, err := n.fetchRemotes(ctx, repo, auth, log, false)
+		if err != nil {
+			return StatusFailed, attempts, nil, backup, err
+		}
+	}
+
+	// Transient failure like timeout or reference has changed
+	// concurrently is retried according to the retry policy
+	attempts, err := n.retry.do(ctx, log, "pull", func() error {
+		err := workTree.PullContext(ctx, &gitPullOption)
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil
+		}
+		return err
+	})
+	if errors.Is(err, git.ErrNonFastForwardUpdate) && n.reconcilesDivergence() {
+		err = n.reconcile(ctx, source+"/"+branch.Short(), &backup, log)
+	}
+	if errors.Is(err, plumbing.ErrObjectNotFound) && isShallow(repo) {
+		err = fmt.Errorf("%w: %v", ErrShallowRepo, err)
+	}
+	if err != nil {
+		return StatusFailed, attempts, nil, backup, err
+	}
+
+	if err := n.pushUpstream(ctx, repo, branch, source, auth, log); err != nil {
+		return StatusFailed, attempts, nil, backup, err
+	}
+	log.Debug("Finish updating repository", zap.String("branch", branch.Short()), zap.String("remote", source), zap.Int("attempts", attempts),
+		zap.Duration("duration", time.Since(start)))
+	return StatusSuccess, attempts, nil, backup, nil
+}
+
+// Check the branch is checked out and already point to the same commit
+// as the remote, using the ref advertisement of the remote (ls-remote)
+// so no object is fetched. Any failure is treated as not up-to-date.
+func (n *node) isUpToDate(ctx context.Context, repo *git.Repository, branch plumbing.ReferenceName, source string, auth *http.BasicAuth) bool {
+	head, err := repo.Head()
+	if err != nil || head.Name() != branch {
+		return false
+	}
+
+	remote, err := repo.Remote(source)
+	if err != nil {
+		return false
+	}
+
+	var refs []*plumbing.Reference
+	log := n.log.With(zap.String("repo", n.path), zap.String("action", "ls-remote"))
+	_, err = n.retry.do(ctx, log, "ls-remote", func() error {
+		refs, err = remote.ListContext(ctx, &git.ListOptions{Auth: transportAuth(auth)})
+		return err
+	})
+	if err != nil {
+		log.Debug("Failed to list remote refs", zap.Error(err))
+		return false
+	}
+
+	for _, ref := range refs {
+		if ref.Name() == branch {
+			return ref.Hash() == head.Hash()
+		}
+	}
+	return false
+}
+
+// Branch being updated, master or main when the repository doesn't have master.
+// Linked worktree keep the branch it has checked out.
+func targetBranch(repo *git.Repository, path string) plumbing.ReferenceName {
+	// The branch of the main worktree can't be checked out in a linked worktree
+	if isLinkedWorktree(path) {
+		if head, err := repo.Head(); err == nil && head.Name().IsBranch() {
+			return head.Name()
+		}
+	}
+
+	if _, err := repo.Reference(plumbing.Master, false); err == nil {
+		return plumbing.Master
+	}
+
+	if _, err := repo.Reference(plumbing.NewBranchReferenceName("main"), false); err == nil {
+		return plumbing.NewBranchReferenceName("main")
+	}
+	return plumbing.Master
+}
+
+// Return the url of the origin remote, empty when the repository has no origin
+func remoteURL(path string) string {
+	repo, err := openRepo(path)
+	if err != nil {
+		return ""
+	}
+
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+// Check whether the repository only has part of the history
+func isShallow(repo *git.Repository) bool {
+	shallow, err := repo.Storer.Shallow()
+	return err == nil && len(shallow) > 0
+}
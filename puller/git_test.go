@@ -0,0 +1,37 @@
+package puller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRepoUpToDate(t *testing.T) {
+	root := t.TempDir()
+	origin := initTestRepo(t, root+"/origin")
+
+	_, err := git.PlainClone(root+"/local", false, &git.CloneOptions{URL: root + "/origin"})
+	require.Nil(t, err)
+
+	n := makeNode(&nodeOptions{
+		path: root + "/local",
+		log:  Log,
+		auth: &Auth{Username: "user", Password: "pass"},
+	})
+
+	status, _, _, _, err := n.pullRepo(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, StatusUpToDate, status)
+
+	commitTestFile(t, origin, "main.go", "package main")
+	status, _, _, _, err = n.pullRepo(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, StatusSuccess, status)
+
+	originHead, _ := origin.Head()
+	localHead, err := localHead(root + "/local")
+	require.Nil(t, err)
+	require.Equal(t, originHead.Hash().String(), localHead)
+}
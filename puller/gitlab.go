@@ -0,0 +1,809 @@
+package puller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+)
+
+type nodeGitlab struct {
+	client     *gitlab.Client
+	group      *gitlab.Group
+	subGroups  []*gitlab.Group
+	projects   []*gitlab.Project
+	Rootdir    string
+	events     *eventBus
+	wg         *sync.WaitGroup
+	log        *zap.Logger
+	auth       *Auth
+	exGroups   map[string]struct{}
+	exProjects map[string]struct{}
+	withShared bool
+	errs       *apiErrors
+	found      *foundRepos
+	retry      RetryPolicy
+	hooks      Hooks
+	submodules bool
+	lfs        bool
+
+	allRemotes  bool
+	pushOrigin  bool
+	allBranches bool
+	divergence  string
+
+	// only list the tree, the directory of the group is not created (export)
+	listOnly bool
+
+	// number of commit being cloned, zero clone the full history
+	depth int
+
+	repoTimeout time.Duration
+}
+
+// gitlabRepo is a project found while enumerating gitlab
+// along with the local directory the project belongs to
+type gitlabRepo struct {
+	dir     string
+	project *gitlab.Project
+}
+
+// foundRepos collect projects found by the concurrent walk
+type foundRepos struct {
+	mu    sync.Mutex
+	repos []gitlabRepo
+}
+
+// gitlabSync hold the state shared by the workers of a gitlab run
+type gitlabSync struct {
+	cloneOnly bool
+	journal   *journal
+	cache     *syncCache
+}
+
+// apiErrors collect errors of gitlab request from the concurrent walk,
+// so the failed page is reported instead of silently being skipped
+type apiErrors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// Update gitlab tree using given credential and root directory
+// Do update if the repo/group present or clone/create the directory of repo is not present
+func (c *engine) UpdateGitlab(ctx context.Context) error {
+	start := time.Now()
+	c.log.Debug("Start proccess update ...", zap.String("action", "update-gitlab"))
+	defer func() {
+		c.log.Debug("Finish execute update-gitlab action", zap.String("action", "update-gitlab"), zap.Duration("duration", time.Since(start)))
+	}()
+
+	return c.syncGitlab(ctx, "update-gitlab", false)
+}
+
+// Perform clone action for every repository in gitlab tree
+// that has not been cloned inside existing tree folder or given directory
+func (c *engine) CloneGitlab(ctx context.Context) error {
+	start := time.Now()
+	c.log.Debug("Start proccess clone ...", zap.String("action", "clone-gitlab"))
+	defer func() {
+		c.log.Debug("Finish execute clone-gitlab action", zap.String("action", "clone-gitlab"), zap.Duration("duration", time.Since(start)))
+	}()
+
+	return c.syncGitlab(ctx, "clone-gitlab", true)
+}
+
+// Enumerate the gitlab tree, then clone or update every project found.
+// The enumerated tree and every finished repository is recorded in the journal,
+// so an interrupted run can be continued using the resume option.
+func (c *engine) syncGitlab(ctx context.Context, action string, cloneOnly bool) error {
+	c.startRun()
+	journal, repos := openJournal(c.dir, action, c.resume, c.log)
+	cache := loadCache(c.dir, c.log)
+	defer cache.save()
+
+	var enumErr error
+	if repos == nil {
+		client, err := c.newGitlabClient()
+		if err != nil {
+			c.events.error("", err)
+			return err
+		}
+
+		repos, enumErr = c.enumerateGitlab(ctx, client, false)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if enumErr != nil {
+			c.events.error("", enumErr)
+		}
+		journal.setRepos(repos, enumErr == nil)
+	}
+	c.events.discoveryFinished(len(repos))
+
+	c.processRepos(ctx, repos, &gitlabSync{
+		cloneOnly: cloneOnly,
+		journal:   journal,
+		cache:     cache,
+	})
+
+	// Interrupted run is reported as canceled instead of the failed requests
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if enumErr != nil {
+		return enumErr
+	}
+
+	journal.finish()
+	return nil
+}
+
+// Find every project that need to be synced, either by walking the group tree
+// or listing the projects the user is a member of. Project from excluded group
+// or excluded by name is not returned. The directory of every group is created unless listOnly is set.
+func (c *engine) enumerateGitlab(ctx context.Context, client *gitlab.Client, listOnly bool) ([]gitlabRepo, error) {
+	if c.membershipOnly {
+		projects, err := listMemberProjects(ctx, client, c.log)
+		if err != nil {
+			return nil, err
+		}
+		return c.flatRepos(projects), nil
+	}
+
+	rootGroups, err := getRootGroups(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg    *sync.WaitGroup = &sync.WaitGroup{}
+		errs  *apiErrors      = &apiErrors{}
+		found *foundRepos     = &foundRepos{}
+	)
+
+	for _, group := range rootGroups {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if _, ok := c.exGroups[group.Name]; ok {
+			continue
+		}
+
+		path := c.dir + "/" + group.Name
+		if !listOnly {
+			createDir(path)
+		}
+
+		node := &nodeGitlab{
+			client:     client,
+			group:      group,
+			Rootdir:    path,
+			wg:         wg,
+			events:     c.events,
+			log:        c.log,
+			exGroups:   c.exGroups,
+			exProjects: c.exProjects,
+			withShared: c.withShared,
+			errs:       errs,
+			found:      found,
+			listOnly:   listOnly,
+		}
+
+		wg.Add(1)
+		go node.walk(ctx)
+	}
+	wg.Wait()
+
+	repos := found.list()
+	if c.owned && ctx.Err() == nil {
+		projects, err := listOwnedProjects(ctx, client, c.log)
+		if err != nil {
+			errs.add(err)
+		}
+		repos = append(repos, c.flatRepos(projects)...)
+	}
+
+	return c.uniqueRepos(repos), errs.err()
+}
+
+// Keep a single repository of every project. Project shared into other groups is listed
+// by each of them and by the personal namespace listing, so it would be cloned several times.
+// The one inside the directory of its own namespace is kept, otherwise the first one.
+func (c *engine) uniqueRepos(repos []gitlabRepo) []gitlabRepo {
+	kept := make(map[int]int)
+	unique := make([]gitlabRepo, 0, len(repos))
+	for _, repo := range repos {
+		i, ok := kept[repo.project.ID]
+		if !ok {
+			kept[repo.project.ID] = len(unique)
+			unique = append(unique, repo)
+			continue
+		}
+
+		skipped := repo
+		if repo.dir == projectDir(c.dir, repo.project) {
+			skipped, unique[i] = unique[i], repo
+		}
+		c.log.Debug("Skip project listed twice", zap.String("repo", skipped.path()), zap.String("kept", unique[i].path()))
+	}
+	return unique
+}
+
+// Clone or update the repositories using a pool of workers.
+// Failure is emitted as the result, finished repository is marked in the journal.
+func (c *engine) processRepos(ctx context.Context, repos []gitlabRepo, run *gitlabSync) {
+	workers := c.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan gitlabRepo)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range jobs {
+				c.processRepo(ctx, repo, run)
+			}
+		}()
+	}
+
+	for _, repo := range repos {
+		// Stop scheduling new repository when the run is canceled
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- repo
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Clone or update a single repository. With incremental option, repository
+// that has no activity on gitlab and no local change since the previous run is skipped.
+func (c *engine) processRepo(ctx context.Context, repo gitlabRepo, run *gitlabSync) {
+	if !run.cloneOnly && c.incremental && run.cache.unchanged(repo) {
+		c.log.Debug("Skip repository without activity since the previous run", zap.String("repo", repo.path()), zap.String("action", ActionUpdate))
+		c.events.finished(RepoResult{
+			Path:   repo.path(),
+			Action: ActionUpdate,
+			Status: StatusSkipped,
+		})
+		run.journal.done(repo)
+		return
+	}
+
+	action := ActionUpdate
+	if run.cloneOnly {
+		action = ActionClone
+	}
+
+	if err := os.MkdirAll(repo.dir, os.ModePerm); err != nil {
+		c.events.finished(RepoResult{
+			Path:   repo.path(),
+			Action: action,
+			Status: StatusFailed,
+			Err:    err,
+		})
+		return
+	}
+
+	node := &nodeGitlab{
+		Rootdir:     repo.dir,
+		events:      c.events,
+		log:         c.log,
+		auth:        c.auth,
+		retry:       c.retry,
+		hooks:       c.hooks,
+		submodules:  c.submodules,
+		lfs:         c.lfs,
+		allRemotes:  c.allRemotes,
+		pushOrigin:  c.pushOrigin,
+		allBranches: c.allBranches,
+		divergence:  c.divergence,
+		depth:       c.depth,
+
+		repoTimeout: c.repoTimeout,
+	}
+
+	var (
+		synced bool
+		err    error
+	)
+	if run.cloneOnly {
+		synced, err = node.cloneIfNotExist(ctx, repo.project)
+	} else {
+		err = node.cloneOrUpdateRepo(ctx, repo.project)
+		synced = ctx.Err() == nil
+	}
+
+	// Failure is already emitted as the result of the repository
+	if err != nil {
+		return
+	}
+
+	// Existing repository skipped by clone-gitlab is not pulled, its local HEAD
+	// may be behind the activity of gitlab, so it's not recorded in the cache
+	if synced {
+		run.cache.record(repo)
+	}
+	run.journal.done(repo)
+}
+
+// Create gitlab api client using the token and the base url of the command
+func (c *engine) newGitlabClient() (*gitlab.Client, error) {
+	clientFuncOpt := gitlabLimiterOptions(c.rateLimit)
+	if c.baseurl != "" {
+		clientFuncOpt = append(clientFuncOpt, gitlab.WithBaseURL(c.baseurl))
+	}
+
+	return gitlab.NewClient(c.auth.Password, clientFuncOpt...)
+}
+
+// Record failed gitlab request
+func (e *apiErrors) add(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errs = append(e.errs, err)
+}
+
+// Return the first failed request along with the total of failures
+func (e *apiErrors) err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch len(e.errs) {
+	case 0:
+		return nil
+	case 1:
+		return e.errs[0]
+	default:
+		return fmt.Errorf("%v gitlab requests failed, first error: %w", len(e.errs), e.errs[0])
+	}
+}
+
+// Record project found by the walk
+func (f *foundRepos) add(repo gitlabRepo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.repos = append(f.repos, repo)
+}
+
+// Return the found projects ordered by its path,
+// so the order doesn't depend on which walk finished first
+func (f *foundRepos) list() []gitlabRepo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	repos := append([]gitlabRepo(nil), f.repos...)
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].path() < repos[j].path()
+	})
+	return repos
+}
+
+// Local path of the repository
+func (r gitlabRepo) path() string {
+	return r.dir + "/" + r.project.Name
+}
+
+func getRootGroups(ctx context.Context, c *gitlab.Client) ([]*gitlab.Group, error) {
+	var groups []*gitlab.Group
+
+	opt := &gitlab.ListGroupsOptions{
+		TopLevelOnly: gitlab.Bool(true),
+	}
+	for {
+		nextGroups, resp, err := c.Groups.ListGroups(opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, nextGroups...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return groups, nil
+}
+
+// List projects and subgroups inside a gitlab group. Every project is recorded
+// to be synced later, while every subgroup is being walked concurrently.
+func (n *nodeGitlab) walk(ctx context.Context) {
+	defer n.wg.Done()
+
+	n.events.enumerating(n.Rootdir)
+
+	if err := n.getAllProjects(ctx); err != nil {
+		n.errs.add(err)
+	} else {
+		n.filterProjects()
+		if len(n.projects) > 0 {
+			listProject := ""
+			for _, project := range n.projects {
+				listProject += project.Name + " | "
+				n.found.add(gitlabRepo{dir: n.Rootdir, project: project})
+				n.events.discovered(n.Rootdir + "/" + project.Name)
+			}
+			n.log.Debug("List project", zap.String("group", n.group.FullName), zap.String("projects", listProject))
+		}
+	}
+
+	if err := n.getAllSubgroups(ctx); err != nil {
+		n.errs.add(err)
+		return
+	}
+	n.filterGroups()
+
+	if len(n.subGroups) > 0 {
+		listGroup := ""
+		for _, group := range n.subGroups {
+			listGroup += group.Name + " | "
+		}
+		n.log.Debug("List subgroup", zap.String("group", n.group.FullName), zap.String("subgroups", listGroup))
+	}
+
+	for _, group := range n.subGroups {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Recursive check the subgroups
+		node := n.child(group)
+		if !n.listOnly {
+			createDir(node.Rootdir)
+		}
+
+		n.wg.Add(1)
+		go node.walk(ctx)
+	}
+}
+
+// Create node for the subgroup. The node share client, options
+// and the wait group with its parent but has its own directory.
+func (n *nodeGitlab) child(group *gitlab.Group) *nodeGitlab {
+	node := *n
+	node.group = group
+	node.Rootdir = n.Rootdir + "/" + group.Name
+	node.subGroups = nil
+	node.projects = nil
+	return &node
+}
+
+// Fetch all subgroups in a group. By default gitlab only returns 20 results at a time.
+// We need to loop over the page to get all the projects and return it.
+func (n *nodeGitlab) getAllSubgroups(ctx context.Context) error {
+	var subGroups []*gitlab.Group
+
+	opt := &gitlab.ListSubGroupsOptions{}
+	for {
+		nextSubGroups, resp, err := n.client.Groups.ListSubGroups(n.group.ID, opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("list subgroups of %v: %w", n.group.Name, err)
+		}
+
+		subGroups = append(subGroups, nextSubGroups...)
+		if resp.NextPage == 0 {
+			if resp.TotalPages > 1 {
+				n.log.Debug("Total page subgroup", zap.String("group", n.group.FullName), zap.Int("pages", resp.TotalPages))
+			}
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	n.subGroups = subGroups
+	return nil
+}
+
+func (n *nodeGitlab) filterGroups() {
+	size := len(n.subGroups)
+	if size == 0 {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		if _, ok := n.exGroups[n.subGroups[i].Name]; ok {
+			newGroups := make([]*gitlab.Group, 0)
+			newGroups = append(newGroups, n.subGroups[:i]...)
+			n.subGroups = append(newGroups, n.subGroups[i+1:]...)
+			size--
+			i--
+		}
+	}
+}
+
+// Clone the project only when it is not present inside the node directory,
+// the present one is emitted as skipped. It return whether the project was cloned.
+func (n *nodeGitlab) cloneIfNotExist(ctx context.Context, p *gitlab.Project) (bool, error) {
+	path := n.Rootdir + "/" + p.Name
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return true, n.cloneRepo(ctx, path, p)
+	}
+
+	n.events.finished(RepoResult{
+		Path:   path,
+		Action: ActionClone,
+		Status: StatusSkipped,
+	})
+	return false, nil
+}
+
+// Fetch all projects in a group, looping over every page of the result
+func (n *nodeGitlab) getAllProjects(ctx context.Context) error {
+	var projects []*gitlab.Project
+
+	opt := &gitlab.ListGroupProjectsOptions{
+		WithShared: gitlab.Bool(n.withShared),
+	}
+	for {
+		nextProject, resp, err := n.client.Groups.ListGroupProjects(n.group.ID, opt, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("list projects of %v: %w", n.group.Name, err)
+		}
+
+		projects = append(projects, nextProject...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	n.projects = projects
+	return nil
+}
+
+func (n *nodeGitlab) filterProjects() {
+	size := len(n.projects)
+	if size == 0 {
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		if _, ok := n.exProjects[n.projects[i].Name]; ok {
+			newProjects := make([]*gitlab.Project, 0)
+			newProjects = append(newProjects, n.projects[:i]...)
+			n.projects = append(newProjects, n.projects[i+1:]...)
+			size--
+			i--
+		}
+	}
+}
+
+// Create the folder of given directory if not exist
+func createDir(path string) {
+	err := os.Mkdir(path, os.ModePerm)
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		return
+	}
+}
+
+// Check whether the repository is Exist
+// Do update repo if exist otherwise clone the repo
+func (n *nodeGitlab) cloneOrUpdateRepo(ctx context.Context, p *gitlab.Project) error {
+	path := n.Rootdir + "/" + p.Name
+	_, err := os.Stat(path)
+	if err == nil || (err != nil && os.IsExist(err)) {
+		node := makeNode(&nodeOptions{
+			path:       path,
+			hardReset:  false,
+			events:     n.events,
+			log:        n.log,
+			auth:       n.auth,
+			retry:      n.retry,
+			hooks:      n.hooks,
+			submodules: n.submodules,
+			lfs:        n.lfs,
+
+			allRemotes:  n.allRemotes,
+			pushOrigin:  n.pushOrigin,
+			allBranches: n.allBranches,
+			divergence:  n.divergence,
+
+			repoTimeout: n.repoTimeout,
+		})
+		return node.updateRepo(ctx)
+	}
+
+	if os.IsNotExist(err) {
+		return n.cloneRepo(ctx, path, p)
+	}
+
+	n.events.finished(RepoResult{
+		Path:   path,
+		Action: ActionUpdate,
+		Status: StatusFailed,
+		Err:    err,
+	})
+	return err
+}
+
+// Clone repo from given path and url
+// Repo name will using dir name (include case sensitive).
+// The full history is cloned unless the depth is set, go-git can't fetch into a shallow repository.
+// Canceled or failed clone is rolled back by removing the partial directory.
+func (n *nodeGitlab) cloneRepo(ctx context.Context, path string, p *gitlab.Project) error {
+	return n.clone(ctx, path, p.Name, cloneSpec{url: p.HTTPURLToRepo, depth: n.depth})
+}
+
+// cloneSpec define what is cloned, zero value field use the default
+type cloneSpec struct {
+	// Url of the repository
+	url string
+
+	// Branch being cloned, master (or main when it doesn't exist) by default
+	branch plumbing.ReferenceName
+
+	// Number of commit being fetched, zero clone the full history.
+	// go-git can't fetch into a shallow repository, so it can't be updated.
+	depth int
+
+	// Name of the remote, origin by default
+	remote string
+
+	// Commit (full or abbreviated SHA) the branch is reset to after the clone,
+	// the tip of the branch by default
+	commit string
+}
+
+// Return the entries of the directory, nil when it doesn't exist
+func dirEntries(path string) (map[string]struct{}, error) {
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		names[entry.Name()] = struct{}{}
+	}
+	return names, nil
+}
+
+// Remove what was created inside the directory: the whole directory when it didn't exist
+// (nil entries), otherwise only the entries it didn't have
+func removeCreated(path string, existing map[string]struct{}) {
+	if existing == nil {
+		_ = os.RemoveAll(path)
+		return
+	}
+
+	entries, _ := os.ReadDir(path)
+	for _, entry := range entries {
+		if _, ok := existing[entry.Name()]; !ok {
+			_ = os.RemoveAll(filepath.Join(path, entry.Name()))
+		}
+	}
+}
+
+// Move the checked out branch to the commit, the worktree is hard reset
+func resetToCommit(path string, commit string) error {
+	repo, err := openRepo(path)
+	if err != nil {
+		return err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(commit))
+	if err != nil {
+		return fmt.Errorf("commit %v: %w", commit, err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Reset(&git.ResetOptions{Commit: *hash, Mode: git.HardReset})
+}
+
+// Clone the repository into the path, the name is only used in the error
+func (n *nodeGitlab) clone(ctx context.Context, path string, name string, spec cloneSpec) error {
+	auth := basicAuth(n.auth)
+	var option *git.CloneOptions = &git.CloneOptions{
+		URL:           spec.url,
+		Auth:          transportAuth(auth),
+		RemoteName:    spec.remote,
+		ReferenceName: plumbing.Master,
+		SingleBranch:  true,
+		Depth:         spec.depth,
+		Tags:          git.NoTags,
+		Progress:      n.events.progressWriter(path, ActionClone, PhaseClone),
+	}
+	if spec.branch != "" {
+		option.ReferenceName = spec.branch
+	}
+
+	repoCtx, cancel := withRepoTimeout(ctx, n.repoTimeout)
+	defer cancel()
+
+	n.events.started(path, ActionClone)
+	start := time.Now()
+	log := n.log.With(zap.String("repo", path), zap.String("action", ActionClone))
+
+	// Only what the clone created is removed, the directory is kept when it already existed
+	existing, err := dirEntries(path)
+	if err != nil {
+		n.events.finished(RepoResult{Path: path, Action: ActionClone, Status: StatusFailed, Err: err, Duration: time.Since(start)})
+		return fmt.Errorf("Repo %v: %w, \nPath: %v", name, err, path)
+	}
+
+	log.Debug("Clonning repository", zap.String("url", spec.url))
+	attempts, err := n.retry.do(repoCtx, log, "clone", func() error {
+		_, err := git.PlainCloneContext(repoCtx, path, false, option)
+		if err != nil && spec.branch == "" && git.NoMatchingRefSpecError.Is(git.NoMatchingRefSpecError{}, err) {
+			removeCreated(path, existing)
+			option.ReferenceName = "refs/heads/main"
+			_, err = git.PlainCloneContext(repoCtx, path, false, option)
+		}
+		if err == nil && spec.commit != "" {
+			err = resetToCommit(path, spec.commit)
+		}
+
+		// Remove the partial clone so the next attempt start from a clean directory
+		if err != nil {
+			removeCreated(path, existing)
+		}
+		return err
+	})
+
+	err = repoTimeoutError(ctx, repoCtx, n.repoTimeout, err)
+	if err != nil {
+		n.events.finished(RepoResult{
+			Path:     path,
+			Action:   ActionClone,
+			Status:   StatusFailed,
+			Attempts: attempts,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+		return fmt.Errorf("Repo %v: %w, \nPath: %v", name, err, path)
+	}
+
+	var subs []SubmoduleResult
+	if n.submodules {
+		n.events.phase(path, ActionClone, PhaseSubmodule)
+		subs = updateSubmodules(repoCtx, n.log, n.retry, path, auth)
+	}
+
+	var lfs *LFSResult
+	if n.lfs {
+		n.events.phase(path, ActionClone, PhaseLFS)
+		lfs = fetchLFS(repoCtx, n.log, n.retry, path, n.auth)
+	}
+
+	var hooks []HookResult
+	if n.hooks.PostClone != "" {
+		env := hookEnv{path: path, action: ActionClone, url: spec.url}
+		env.newSHA, _ = localHead(path)
+		hooks = appendHook(hooks, runHook(ctx, n.log, hookPostClone, n.hooks.PostClone, env))
+	}
+
+	n.events.finished(RepoResult{
+		Path:       path,
+		Action:     ActionClone,
+		Status:     StatusSuccess,
+		Attempts:   attempts,
+		Hooks:      hooks,
+		Submodules: subs,
+		LFS:        lfs,
+		Duration:   time.Since(start),
+	})
+	return nil
+}
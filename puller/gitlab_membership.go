@@ -0,0 +1,124 @@
+package puller
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+)
+
+// Directory used as the parent of every personal namespace
+const userNamespaceDir = "users"
+
+// List projects inside the authenticated user's personal namespace
+func listOwnedProjects(ctx context.Context, client *gitlab.Client, log *zap.Logger) ([]*gitlab.Project, error) {
+	user, _, err := client.Users.CurrentUser(gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	projects, err := listProjects(func(opt *gitlab.ListProjectsOptions) ([]*gitlab.Project, *gitlab.Response, error) {
+		return client.Projects.ListUserProjects(user.ID, opt, gitlab.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Found projects in personal namespace", zap.String("group", user.Username), zap.Int("projects", len(projects)))
+	return projects, nil
+}
+
+// List every project the authenticated user is a member of.
+// Unlike the group walk this also covers personal namespaces and
+// projects that only being shared to the user.
+func listMemberProjects(ctx context.Context, client *gitlab.Client, log *zap.Logger) ([]*gitlab.Project, error) {
+	projects, err := listProjects(func(opt *gitlab.ListProjectsOptions) ([]*gitlab.Project, *gitlab.Response, error) {
+		opt.Membership = gitlab.Bool(true)
+		return client.Projects.ListProjects(opt, gitlab.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("Found projects with membership", zap.Int("projects", len(projects)))
+	return projects, nil
+}
+
+// Fetch every page of a project listing
+func listProjects(list func(*gitlab.ListProjectsOptions) ([]*gitlab.Project, *gitlab.Response, error)) ([]*gitlab.Project, error) {
+	var projects []*gitlab.Project
+
+	opt := &gitlab.ListProjectsOptions{}
+	for {
+		page, resp, err := list(opt)
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return projects, nil
+}
+
+// Place a flat list of projects into its namespace directory.
+// Project that belongs to an excluded group or excluded by name will be skipped.
+func (c *engine) flatRepos(projects []*gitlab.Project) []gitlabRepo {
+	repos := make([]gitlabRepo, 0, len(projects))
+	for _, project := range projects {
+		if c.isExcluded(project) {
+			c.log.Debug("Skip excluded project", zap.String("repo", project.NameWithNamespace))
+			continue
+		}
+
+		repos = append(repos, gitlabRepo{
+			dir:     projectDir(c.dir, project),
+			project: project,
+		})
+	}
+	return repos
+}
+
+// Check the project name or one of its namespace is being excluded
+func (c *engine) isExcluded(p *gitlab.Project) bool {
+	if _, ok := c.exProjects[p.Name]; ok {
+		return true
+	}
+
+	for _, name := range namespaceNames(p) {
+		if _, ok := c.exGroups[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve the parent directory of a project listed outside of the group walk.
+// Group projects follow the same group name tree used by the walk, while projects
+// inside a personal namespace are placed under "users/<username>".
+func projectDir(root string, p *gitlab.Project) string {
+	if p.Namespace != nil && p.Namespace.Kind == "user" {
+		return root + "/" + userNamespaceDir + "/" + p.Namespace.Path
+	}
+
+	names := namespaceNames(p)
+	if len(names) == 0 {
+		return root
+	}
+	return root + "/" + strings.Join(names, "/")
+}
+
+// List the group names of the project namespace from the top level group
+func namespaceNames(p *gitlab.Project) []string {
+	names := strings.Split(p.NameWithNamespace, " / ")
+	if len(names) < 2 {
+		return nil
+	}
+	return names[:len(names)-1]
+}
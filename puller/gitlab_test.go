@@ -0,0 +1,106 @@
+package puller
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+)
+
+func TestCloneGitlab(t *testing.T) {
+	zLog, _ := zap.NewDevelopment()
+	n := &nodeGitlab{
+		auth: &Auth{
+			Username: "kevin.chandra",
+			Password: "wings123",
+		},
+		log: zLog,
+	}
+	p := &gitlab.Project{
+		Name:          "External",
+		HTTPURLToRepo: "http://172.20.5.240/wingsdev/dependency/external.git",
+	}
+
+	t.Log("Start pulling project ...")
+	n.cloneRepo(context.Background(), "D:/temp/test/WingsDev/Dependency/External", p)
+	t.Log("Done Pull Project")
+}
+
+func TestProjectDir(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Project  *gitlab.Project
+		Expected string
+	}{
+		{
+			Name: "GroupProject",
+			Project: &gitlab.Project{
+				Name:              "External",
+				NameWithNamespace: "WingsDev / Dependency / External",
+				Namespace:         &gitlab.ProjectNamespace{Kind: "group", Path: "dependency"},
+			},
+			Expected: "root/WingsDev/Dependency",
+		},
+		{
+			Name: "PersonalProject",
+			Project: &gitlab.Project{
+				Name:              "Dotfiles",
+				NameWithNamespace: "Kevin Chandra / Dotfiles",
+				Namespace:         &gitlab.ProjectNamespace{Kind: "user", Path: "kevin.chandra"},
+			},
+			Expected: "root/users/kevin.chandra",
+		},
+		{
+			Name: "NoNamespace",
+			Project: &gitlab.Project{
+				Name:              "Lonely",
+				NameWithNamespace: "Lonely",
+			},
+			Expected: "root",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			require.Equal(t, test.Expected, projectDir("root", test.Project))
+		})
+	}
+}
+
+func TestIsExcluded(t *testing.T) {
+	c := &engine{
+		exGroups:   map[string]struct{}{"Dependency": {}},
+		exProjects: map[string]struct{}{"Legacy": {}},
+	}
+
+	require.True(t, c.isExcluded(&gitlab.Project{Name: "External", NameWithNamespace: "WingsDev / Dependency / External"}))
+	require.True(t, c.isExcluded(&gitlab.Project{Name: "Legacy", NameWithNamespace: "WingsDev / Legacy"}))
+	require.False(t, c.isExcluded(&gitlab.Project{Name: "Api", NameWithNamespace: "WingsDev / Backend / Api"}))
+}
+
+func TestCloneFailureCleanup(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.MkdirAll(dir+"/existing", os.ModePerm))
+	require.Nil(t, os.WriteFile(dir+"/existing/notes.txt", []byte("user data"), 0644))
+
+	n := &nodeGitlab{
+		log:    Log,
+		events: newEventBus(&report{}),
+		retry:  RetryPolicy{MaxAttempts: 1},
+	}
+	spec := cloneSpec{url: dir + "/bare/missing.git"}
+
+	// Directory created by the clone is removed
+	require.NotNil(t, n.clone(context.Background(), dir+"/created", "created", spec))
+	require.NoDirExists(t, dir+"/created")
+
+	// Directory that existed is kept along with its content, only the clone is removed
+	require.NotNil(t, n.clone(context.Background(), dir+"/existing", "existing", spec))
+	entries, err := os.ReadDir(dir + "/existing")
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "notes.txt", entries[0].Name())
+}
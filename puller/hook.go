@@ -0,0 +1,99 @@
+package puller
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	hookPreUpdate  = "pre-update"
+	hookPostClone  = "post-clone"
+	hookPostUpdate = "post-update"
+)
+
+// Hooks are shell commands being run inside every repository directory.
+// The repository is described by the GGP_* environment variables:
+// GGP_REPO_PATH, GGP_REPO_NAME, GGP_ACTION, GGP_OLD_SHA, GGP_NEW_SHA and GGP_REMOTE_URL.
+type Hooks struct {
+	// Run before the repository is updated, GGP_NEW_SHA is empty
+	PreUpdate string
+
+	// Run after the repository is cloned, GGP_OLD_SHA is empty
+	PostClone string
+
+	// Run after the repository is updated, including up-to-date repository
+	PostUpdate string
+}
+
+// Check whether any hook is configured
+func (h Hooks) empty() bool {
+	return h == Hooks{}
+}
+
+// Repository being passed to the hook
+type hookEnv struct {
+	path   string
+	action string
+	oldSHA string
+	newSHA string
+	url    string
+}
+
+// Run the hook command inside the repository directory. Empty command is
+// not run and return nil. Failure is only recorded in the result,
+// so the run continue with the other repository.
+func runHook(ctx context.Context, log *zap.Logger, name string, command string, env hookEnv) *HookResult {
+	if command == "" || ctx.Err() != nil {
+		return nil
+	}
+
+	start := time.Now()
+	log = log.With(zap.String("repo", env.path), zap.String("action", env.action), zap.String("hook", name))
+
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = env.path
+	cmd.Env = append(os.Environ(),
+		"GGP_REPO_PATH="+env.path,
+		"GGP_REPO_NAME="+filepath.Base(env.path),
+		"GGP_ACTION="+env.action,
+		"GGP_OLD_SHA="+env.oldSHA,
+		"GGP_NEW_SHA="+env.newSHA,
+		"GGP_REMOTE_URL="+env.url,
+	)
+
+	output, err := cmd.CombinedOutput()
+	res := &HookResult{
+		Name:   name,
+		Output: string(output),
+		Err:    err,
+	}
+
+	if err != nil {
+		log.Warn("Hook failed", zap.Error(err), zap.String("output", res.Output), zap.Duration("duration", time.Since(start)))
+		return res
+	}
+	log.Debug("Hook finished", zap.String("output", res.Output), zap.Duration("duration", time.Since(start)))
+	return res
+}
+
+// Command run by the system shell, so the hook can use pipe and variable
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// Append the result of hook being run
+func appendHook(hooks []HookResult, res *HookResult) []HookResult {
+	if res == nil {
+		return hooks
+	}
+	return append(hooks, *res)
+}
@@ -0,0 +1,64 @@
+package puller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	env := hookEnv{path: dir, action: ActionUpdate, oldSHA: "aaa", newSHA: "bbb", url: "http://localhost/repo.git"}
+
+	res := runHook(context.Background(), Log, hookPostUpdate, `echo "$GGP_ACTION $GGP_OLD_SHA $GGP_NEW_SHA $GGP_REMOTE_URL"; pwd`, env)
+	require.NotNil(t, res)
+	require.Nil(t, res.Err)
+	require.Equal(t, "update aaa bbb http://localhost/repo.git\n"+dir+"\n", res.Output)
+
+	res = runHook(context.Background(), Log, hookPreUpdate, "echo broken; exit 3", env)
+	require.NotNil(t, res)
+	require.NotNil(t, res.Err)
+	require.Equal(t, "broken\n", res.Output)
+
+	require.Nil(t, runHook(context.Background(), Log, hookPostClone, "", env))
+}
+
+func TestUpdateRepoHooks(t *testing.T) {
+	root := t.TempDir()
+	origin := initTestRepo(t, root+"/origin")
+
+	_, err := git.PlainClone(root+"/local", false, &git.CloneOptions{URL: root + "/origin"})
+	require.Nil(t, err)
+	oldHead, err := localHead(root + "/local")
+	require.Nil(t, err)
+	commitTestFile(t, origin, "main.go", "package main")
+	newHead, _ := origin.Head()
+
+	r := &report{}
+	n := makeNode(&nodeOptions{
+		path:   root + "/local",
+		log:    Log,
+		auth:   &Auth{Username: "user", Password: "pass"},
+		events: newEventBus(r),
+		hooks: Hooks{
+			PreUpdate:  `test -z "$GGP_NEW_SHA" && echo "$GGP_OLD_SHA"`,
+			PostUpdate: `echo "$GGP_OLD_SHA $GGP_NEW_SHA"; exit 1`,
+		},
+	})
+
+	require.Nil(t, n.updateRepo(context.Background()))
+	require.Len(t, r.results, 1)
+	require.Equal(t, StatusSuccess, r.results[0].Status)
+
+	hooks := r.results[0].Hooks
+	require.Len(t, hooks, 2)
+	require.Equal(t, hookPreUpdate, hooks[0].Name)
+	require.Nil(t, hooks[0].Err)
+	require.Equal(t, oldHead+"\n", hooks[0].Output)
+	require.Equal(t, hookPostUpdate, hooks[1].Name)
+	require.NotNil(t, hooks[1].Err)
+	require.Equal(t, oldHead+" "+newHead.Hash().String()+"\n", hooks[1].Output)
+	require.Equal(t, "exit status 1", hooks[1].Err.Error())
+}
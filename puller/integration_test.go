@@ -0,0 +1,491 @@
+package puller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/require"
+)
+
+// testRemote is a bare repository on disk served through the file transport,
+// commit is done on its work repository then pushed into the bare one
+type testRemote struct {
+	url  string
+	work *git.Repository
+}
+
+// Create bare repository with a single commit inside the directory
+func newTestRemote(t *testing.T, dir string, name string) *testRemote {
+	work := initTestRepo(t, dir+"/work/"+name)
+
+	url := dir + "/bare/" + name + ".git"
+	_, err := git.PlainClone(url, true, &git.CloneOptions{URL: dir + "/work/" + name})
+	require.Nil(t, err)
+
+	_, err = work.CreateRemote(&config.RemoteConfig{Name: "bare", URLs: []string{url}})
+	require.Nil(t, err)
+	return &testRemote{url: url, work: work}
+}
+
+// Commit the file and push it into the bare repository
+func (r *testRemote) commit(t *testing.T, name string, content string) {
+	commitTestFile(t, r.work, name, content)
+	require.Nil(t, r.work.Push(&git.PushOptions{RemoteName: "bare"}))
+}
+
+type fakeGroup struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	FullName string `json:"full_name"`
+	FullPath string `json:"full_path"`
+	ParentID int    `json:"parent_id"`
+}
+
+type fakeNamespace struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+type fakeProject struct {
+	ID                int           `json:"id"`
+	Name              string        `json:"name"`
+	Path              string        `json:"path"`
+	NameWithNamespace string        `json:"name_with_namespace"`
+	HTTPURLToRepo     string        `json:"http_url_to_repo"`
+	LastActivityAt    time.Time     `json:"last_activity_at"`
+	Namespace         fakeNamespace `json:"namespace"`
+
+	group  int
+	shared []int
+}
+
+// fakeGitlab serve the groups and projects api of gitlab from memory,
+// every response is a single page
+type fakeGitlab struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	groups   []fakeGroup
+	projects []fakeProject
+
+	// status code returned for the path instead of the result
+	fail map[string]int
+}
+
+func newFakeGitlab(t *testing.T) *fakeGitlab {
+	f := &fakeGitlab{fail: make(map[string]int)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// Add group and return its id, parent zero means root group
+func (f *fakeGitlab) addGroup(parent int, name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	group := fakeGroup{ID: len(f.groups) + 1, Name: name, Path: strings.ToLower(name), FullName: name, ParentID: parent}
+	for _, g := range f.groups {
+		if g.ID == parent {
+			group.FullName = g.FullName + " / " + name
+		}
+	}
+	group.FullPath = strings.ToLower(strings.ReplaceAll(group.FullName, " / ", "/"))
+	f.groups = append(f.groups, group)
+	return group.ID
+}
+
+func (f *fakeGitlab) addProject(group int, name string, url string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	project := fakeProject{
+		ID:             len(f.projects) + 1,
+		Name:           name,
+		Path:           strings.ToLower(name),
+		HTTPURLToRepo:  url,
+		LastActivityAt: time.Now(),
+		Namespace:      fakeNamespace{Kind: "group"},
+		group:          group,
+	}
+	for _, g := range f.groups {
+		if g.ID == group {
+			project.NameWithNamespace = g.FullName + " / " + name
+			project.Namespace.Path = g.FullPath
+		}
+	}
+	f.projects = append(f.projects, project)
+}
+
+// Share the project into the group, it's listed by the group with the shared projects
+func (f *fakeGitlab) share(name string, group int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.projects {
+		if f.projects[i].Name == name {
+			f.projects[i].shared = append(f.projects[i].shared, group)
+		}
+	}
+}
+
+// Move the last activity of the project forward, as gitlab does on push
+func (f *fakeGitlab) touch(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.projects {
+		if f.projects[i].Name == name {
+			f.projects[i].LastActivityAt = f.projects[i].LastActivityAt.Add(time.Minute)
+		}
+	}
+}
+
+func (f *fakeGitlab) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v4")
+	if code, ok := f.fail[path]; ok {
+		http.Error(w, `{"message":"failed"}`, code)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var result interface{}
+	switch {
+	case len(parts) == 1 && parts[0] == "groups":
+		groups := make([]fakeGroup, 0)
+		for _, g := range f.groups {
+			if g.ParentID == 0 {
+				groups = append(groups, g)
+			}
+		}
+		result = groups
+	case len(parts) == 3 && parts[0] == "groups" && parts[2] == "subgroups":
+		id, _ := strconv.Atoi(parts[1])
+		groups := make([]fakeGroup, 0)
+		for _, g := range f.groups {
+			if g.ParentID == id {
+				groups = append(groups, g)
+			}
+		}
+		result = groups
+	case len(parts) == 3 && parts[0] == "groups" && parts[2] == "projects":
+		id, _ := strconv.Atoi(parts[1])
+		projects := make([]fakeProject, 0)
+		withShared := r.URL.Query().Get("with_shared") == "true"
+		for _, p := range f.projects {
+			if p.group == id || (withShared && containsID(p.shared, id)) {
+				projects = append(projects, p)
+			}
+		}
+		result = projects
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// testWorkspace is the fake gitlab along with the remotes and the local root directory
+type testWorkspace struct {
+	gitlab  *fakeGitlab
+	remotes map[string]*testRemote
+	dir     string
+	root    string
+}
+
+// Create workspace with the tree:
+//
+//	Team (api, web, legacy), Team / Libs (util), Archive (old)
+func newTestWorkspace(t *testing.T) *testWorkspace {
+	ws := &testWorkspace{
+		gitlab:  newFakeGitlab(t),
+		remotes: make(map[string]*testRemote),
+		dir:     t.TempDir(),
+		root:    t.TempDir(),
+	}
+
+	team := ws.gitlab.addGroup(0, "Team")
+	libs := ws.gitlab.addGroup(team, "Libs")
+	archive := ws.gitlab.addGroup(0, "Archive")
+
+	for group, names := range map[int][]string{team: {"api", "web", "legacy"}, libs: {"util"}, archive: {"old"}} {
+		for _, name := range names {
+			ws.remotes[name] = newTestRemote(t, ws.dir, name)
+			ws.gitlab.addProject(group, name, ws.remotes[name].url)
+		}
+	}
+	return ws
+}
+
+// Create syncer for the workspace, excluding Archive group and legacy project
+func (ws *testWorkspace) syncer(t *testing.T, opt Options) (*Syncer, *recorder) {
+	r := &recorder{}
+	opt.Dir = ws.root
+	opt.Baseurl = ws.gitlab.server.URL
+	opt.Auth = &Auth{Username: "token", Password: "token"}
+	opt.Exgroups = []string{"Archive"}
+	opt.Exprojects = []string{"legacy"}
+	opt.Workers = 2
+	opt.Retry = &RetryPolicy{MaxAttempts: 1}
+	opt.Observers = []Observer{r}
+
+	s, err := NewSyncer(&opt, nil)
+	require.Nil(t, err)
+	return s, r
+}
+
+// Return status of every repository by its path relative to the root
+func statusByPath(root string, res *Result) map[string]string {
+	status := make(map[string]string)
+	for _, repo := range res.Repos {
+		status[strings.TrimPrefix(repo.Path, root+"/")] = repo.Status
+	}
+	return status
+}
+
+func TestIntegrationCloneGitlab(t *testing.T) {
+	ws := newTestWorkspace(t)
+	ws.gitlab.addProject(1, "broken", ws.dir+"/bare/missing.git")
+
+	s, r := ws.syncer(t, Options{})
+	res, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+
+	require.Equal(t, map[string]string{
+		"Team/api":       StatusSuccess,
+		"Team/web":       StatusSuccess,
+		"Team/broken":    StatusFailed,
+		"Team/Libs/util": StatusSuccess,
+	}, statusByPath(ws.root, res))
+
+	// Repositories finished by the workers are ordered by their path
+	paths := make([]string, 0)
+	for _, repo := range res.Repos {
+		paths = append(paths, strings.TrimPrefix(repo.Path, ws.root+"/"))
+	}
+	require.Equal(t, []string{"Team/Libs/util", "Team/api", "Team/broken", "Team/web"}, paths)
+
+	// Cloned repository point to the same commit as the remote
+	for path, name := range map[string]string{"Team/api": "api", "Team/web": "web", "Team/Libs/util": "util"} {
+		head, err := localHead(ws.root + "/" + path)
+		require.Nil(t, err)
+		remoteHead, _ := ws.remotes[name].work.Head()
+		require.Equal(t, remoteHead.Hash().String(), head)
+	}
+
+	// Excluded group and project are never cloned, failed clone is rolled back
+	require.NoDirExists(t, ws.root+"/Archive")
+	require.NoDirExists(t, ws.root+"/Team/legacy")
+	require.NoDirExists(t, ws.root+"/Team/broken")
+
+	discovered := make([]string, 0)
+	for _, e := range r.events {
+		if e.Type == EventRepoDiscovered {
+			discovered = append(discovered, strings.TrimPrefix(e.Path, ws.root+"/"))
+		}
+	}
+	sort.Strings(discovered)
+	require.Equal(t, []string{"Team/Libs/util", "Team/api", "Team/broken", "Team/web"}, discovered)
+
+	// The second clone skip every present repository
+	res, err = s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"Team/api":       StatusSkipped,
+		"Team/web":       StatusSkipped,
+		"Team/broken":    StatusFailed,
+		"Team/Libs/util": StatusSkipped,
+	}, statusByPath(ws.root, res))
+}
+
+func TestIntegrationCloneGitlabShared(t *testing.T) {
+	ws := newTestWorkspace(t)
+	ws.gitlab.share("util", 1)
+
+	s, _ := ws.syncer(t, Options{WithShared: true})
+	res, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+
+	// The shared project is cloned once, inside the group it belongs to
+	require.Equal(t, map[string]string{
+		"Team/api":       StatusSuccess,
+		"Team/web":       StatusSuccess,
+		"Team/Libs/util": StatusSuccess,
+	}, statusByPath(ws.root, res))
+	require.Len(t, res.Repos, 3)
+	require.NoDirExists(t, ws.root+"/Team/util")
+}
+
+func TestIntegrationCloneGitlabDepth(t *testing.T) {
+	for _, depth := range []int{0, 1} {
+		ws := newTestWorkspace(t)
+		ws.remotes["api"].commit(t, "go.mod", "module api")
+
+		s, _ := ws.syncer(t, Options{Depth: depth})
+		res, err := s.CloneGitlab(context.Background())
+		require.Nil(t, err)
+		require.Empty(t, res.Failed())
+
+		// The full history is cloned by default
+		repo, err := git.PlainOpen(ws.root + "/Team/api")
+		require.Nil(t, err)
+		require.Equal(t, depth > 0, isShallow(repo), "depth %v", depth)
+	}
+}
+
+func TestIntegrationUpdateGitlab(t *testing.T) {
+	ws := newTestWorkspace(t)
+	ws.remotes["api"].commit(t, "go.mod", "module api")
+
+	s, _ := ws.syncer(t, Options{})
+	_, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+
+	// New commit on api and a new project on the server
+	ws.remotes["api"].commit(t, "main.go", "package main")
+	ws.remotes["cli"] = newTestRemote(t, ws.dir, "cli")
+	ws.gitlab.addProject(2, "cli", ws.remotes["cli"].url)
+
+	res, err := s.UpdateGitlab(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"Team/api":       StatusSuccess,
+		"Team/web":       StatusUpToDate,
+		"Team/Libs/util": StatusUpToDate,
+		"Team/Libs/cli":  StatusSuccess,
+	}, statusByPath(ws.root, res))
+
+	content, err := os.ReadFile(ws.root + "/Team/api/main.go")
+	require.Nil(t, err)
+	require.Equal(t, "package main", string(content))
+
+	actions := make(map[string]string)
+	for _, repo := range res.Repos {
+		actions[strings.TrimPrefix(repo.Path, ws.root+"/")] = repo.Action
+	}
+	require.Equal(t, ActionClone, actions["Team/Libs/cli"])
+	require.Equal(t, ActionUpdate, actions["Team/api"])
+}
+
+func TestIntegrationIncrementalAfterClone(t *testing.T) {
+	ws := newTestWorkspace(t)
+	s, _ := ws.syncer(t, Options{Incremental: true})
+	_, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+
+	// The existing repository is skipped by the second clone, it's not pulled
+	ws.remotes["api"].commit(t, "main.go", "package main")
+	ws.gitlab.touch("api")
+	res, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, StatusSkipped, statusByPath(ws.root, res)["Team/api"])
+
+	res, err = s.UpdateGitlab(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"Team/api":       StatusSuccess,
+		"Team/web":       StatusSkipped,
+		"Team/Libs/util": StatusSkipped,
+	}, statusByPath(ws.root, res))
+	require.FileExists(t, ws.root+"/Team/api/main.go")
+}
+
+func TestIntegrationUpdateLocal(t *testing.T) {
+	ws := newTestWorkspace(t)
+
+	s, _ := ws.syncer(t, Options{})
+	_, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+
+	ws.remotes["util"].commit(t, "util.go", "package util")
+
+	// Shallow clone can't be fetched by go-git, it's reported with a clear error
+	ws.remotes["web"].commit(t, "index.html", "<html>")
+	require.Nil(t, os.RemoveAll(ws.root+"/Team/web"))
+	_, err = git.PlainClone(ws.root+"/Team/web", false, &git.CloneOptions{URL: ws.remotes["web"].url, Depth: 1})
+	require.Nil(t, err)
+	ws.remotes["web"].commit(t, "style.css", "body {}")
+
+	// Local update doesn't need gitlab, the excluded project directory is skipped too
+	require.Nil(t, os.MkdirAll(ws.root+"/Team/legacy", os.ModePerm))
+	res, err := s.Update(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"Team/api":       StatusUpToDate,
+		"Team/web":       StatusFailed,
+		"Team/Libs/util": StatusSuccess,
+	}, statusByPath(ws.root, res))
+	require.FileExists(t, ws.root+"/Team/Libs/util/util.go")
+	require.ErrorIs(t, res.Failed()[0].Err, ErrShallowRepo)
+}
+
+func TestIntegrationGitlabError(t *testing.T) {
+	ws := newTestWorkspace(t)
+
+	t.Run("RootGroupsFailed", func(t *testing.T) {
+		ws.gitlab.fail["/groups"] = http.StatusUnauthorized
+		defer delete(ws.gitlab.fail, "/groups")
+
+		s, _ := ws.syncer(t, Options{})
+		res, err := s.CloneGitlab(context.Background())
+		require.NotNil(t, err)
+		require.Empty(t, res.Repos)
+	})
+
+	t.Run("SubgroupPageFailed", func(t *testing.T) {
+		ws.gitlab.fail["/groups/2/projects"] = http.StatusForbidden
+		defer delete(ws.gitlab.fail, "/groups/2/projects")
+
+		// Repository of the other groups is still cloned, the failed page is returned
+		s, r := ws.syncer(t, Options{})
+		res, err := s.CloneGitlab(context.Background())
+		require.NotNil(t, err)
+		require.True(t, strings.Contains(err.Error(), "list projects of Libs"))
+		require.Equal(t, map[string]string{
+			"Team/api": StatusSuccess,
+			"Team/web": StatusSuccess,
+		}, statusByPath(ws.root, res))
+
+		errorEvents := 0
+		for _, e := range r.events {
+			if e.Type == EventError {
+				errorEvents++
+			}
+		}
+		require.Equal(t, 1, errorEvents)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		s, _ := ws.syncer(t, Options{})
+		_, err := s.UpdateGitlab(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
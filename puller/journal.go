@@ -0,0 +1,215 @@
+package puller
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+	"go.uber.org/zap"
+)
+
+const (
+	// Directory inside the root path used to store the program state
+	stateDir = ".go-git-puller"
+
+	// File name of the journal inside the state directory
+	journalFile = "state.json"
+)
+
+// journal persist the enumerated gitlab tree and the completion of every
+// repository under the root path, so an interrupted run can be resumed
+// without enumerating gitlab again and skipping the finished repositories.
+type journal struct {
+	mu    sync.Mutex
+	root  string
+	path  string
+	log   *zap.Logger
+	state journalState
+	index map[string]int
+}
+
+type journalState struct {
+	// Action that was running, journal is only resumed by the same action
+	Action string `json:"action"`
+
+	// Whether the whole gitlab tree has been enumerated without failure
+	Enumerated bool `json:"enumerated"`
+
+	Repos []journalRepo `json:"repos"`
+}
+
+type journalRepo struct {
+	Name string `json:"name"`
+
+	// Parent directory of the repository relative to the root path
+	Dir string `json:"dir"`
+
+	URL  string `json:"url"`
+	Done bool   `json:"done"`
+
+	// Last activity of the project on gitlab, used by incremental sync
+	LastActivityAt *time.Time `json:"last_activity_at,omitempty"`
+}
+
+// Open the journal of the action inside root directory. When resume is set and
+// the previous run of the same action has finished enumerating the tree,
+// the repositories that have not been finished are returned.
+// Otherwise nil is returned and the tree need to be enumerated again.
+func openJournal(root string, action string, resume bool, log *zap.Logger) (*journal, []gitlabRepo) {
+	j := &journal{
+		root:  root,
+		path:  root + "/" + stateDir + "/" + journalFile,
+		log:   log,
+		state: journalState{Action: action},
+		index: make(map[string]int),
+	}
+
+	if !resume {
+		return j, nil
+	}
+
+	data, err := os.ReadFile(j.path)
+	if err != nil {
+		log.Debug("No journal to be resumed", zap.String("path", j.path), zap.Error(err))
+		return j, nil
+	}
+
+	var state journalState
+	if err = json.Unmarshal(data, &state); err != nil {
+		log.Warn("Journal is corrupted, start a new run", zap.String("path", j.path), zap.Error(err))
+		return j, nil
+	}
+
+	if state.Action != action || !state.Enumerated {
+		log.Info("Journal can't be resumed, start a new run", zap.String("path", j.path), zap.String("action", action))
+		return j, nil
+	}
+
+	j.state = state
+	pending := make([]gitlabRepo, 0)
+	for i, repo := range state.Repos {
+		j.index[j.key(repo.Dir, repo.Name)] = i
+		if repo.Done {
+			continue
+		}
+
+		pending = append(pending, gitlabRepo{
+			dir: absolutePath(j.root, repo.Dir),
+			project: &gitlab.Project{
+				Name:           repo.Name,
+				HTTPURLToRepo:  repo.URL,
+				LastActivityAt: repo.LastActivityAt,
+			},
+		})
+	}
+
+	log.Info("Resume previous run", zap.String("action", action), zap.Int("pending", len(pending)), zap.Int("total", len(state.Repos)))
+	return j, pending
+}
+
+// Record the enumerated repositories, complete tell
+// whether the whole tree was enumerated without failure
+func (j *journal) setRepos(repos []gitlabRepo, complete bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.state.Enumerated = complete
+	j.state.Repos = make([]journalRepo, 0, len(repos))
+	j.index = make(map[string]int)
+	for i, repo := range repos {
+		dir := relativePath(j.root, repo.dir)
+		j.index[j.key(dir, repo.project.Name)] = i
+		j.state.Repos = append(j.state.Repos, journalRepo{
+			Name: repo.project.Name,
+			Dir:  dir,
+			URL:  repo.project.HTTPURLToRepo,
+
+			LastActivityAt: repo.project.LastActivityAt,
+		})
+	}
+
+	j.save()
+}
+
+// Mark the repository as finished
+func (j *journal) done(repo gitlabRepo) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	i, ok := j.index[j.key(relativePath(j.root, repo.dir), repo.project.Name)]
+	if !ok {
+		return
+	}
+
+	j.state.Repos[i].Done = true
+	j.save()
+}
+
+// Remove the journal when every repository has been finished,
+// so the next resume start with a new run
+func (j *journal) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, repo := range j.state.Repos {
+		if !repo.Done {
+			return
+		}
+	}
+
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		j.log.Warn("Failed to remove journal", zap.String("path", j.path), zap.Error(err))
+	}
+}
+
+// Persist the journal into the state directory
+func (j *journal) save() {
+	data, err := json.MarshalIndent(j.state, "", "  ")
+	if err != nil {
+		j.log.Warn("Failed to encode journal", zap.Error(err))
+		return
+	}
+
+	if err = writeState(j.root, j.path, data); err != nil {
+		j.log.Warn("Failed to write journal", zap.String("path", j.path), zap.Error(err))
+	}
+}
+
+// Write file inside the state directory into a temporary file then rename it,
+// so the file is never half written when the program being killed
+func writeState(root, path string, data []byte) error {
+	if err := os.MkdirAll(root+"/"+stateDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (j *journal) key(dir, name string) string {
+	return dir + "/" + name
+}
+
+// Path relative to the root path, so the state still valid
+// when the root path is given in a different form
+func relativePath(root, path string) string {
+	if path == root {
+		return "."
+	}
+	return strings.TrimPrefix(path, root+"/")
+}
+
+// Reverse of the relativePath
+func absolutePath(root, path string) string {
+	if path == "." {
+		return root
+	}
+	return root + "/" + path
+}
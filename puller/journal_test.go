@@ -0,0 +1,63 @@
+package puller
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestJournalResume(t *testing.T) {
+	root := t.TempDir()
+	repos := []gitlabRepo{
+		{dir: root + "/WingsDev", project: &gitlab.Project{Name: "Api", HTTPURLToRepo: "http://gitlab/wingsdev/api.git"}},
+		{dir: root + "/WingsDev/Dependency", project: &gitlab.Project{Name: "External", HTTPURLToRepo: "http://gitlab/wingsdev/dependency/external.git"}},
+	}
+
+	j, pending := openJournal(root, "clone-gitlab", true, Log)
+	require.Nil(t, pending)
+
+	j.setRepos(repos, true)
+	j.done(repos[0])
+
+	t.Run("ResumeSameAction", func(t *testing.T) {
+		_, pending := openJournal(root, "clone-gitlab", true, Log)
+		require.Len(t, pending, 1)
+		require.Equal(t, repos[1].dir, pending[0].dir)
+		require.Equal(t, "External", pending[0].project.Name)
+		require.Equal(t, repos[1].project.HTTPURLToRepo, pending[0].project.HTTPURLToRepo)
+	})
+
+	t.Run("OtherAction", func(t *testing.T) {
+		_, pending := openJournal(root, "update-gitlab", true, Log)
+		require.Nil(t, pending)
+	})
+
+	t.Run("WithoutResume", func(t *testing.T) {
+		_, pending := openJournal(root, "clone-gitlab", false, Log)
+		require.Nil(t, pending)
+	})
+
+	t.Run("FinishRemoveJournal", func(t *testing.T) {
+		j.finish()
+		_, err := os.Stat(j.path)
+		require.Nil(t, err)
+
+		j.done(repos[1])
+		j.finish()
+		_, err = os.Stat(j.path)
+		require.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestJournalNotEnumerated(t *testing.T) {
+	root := t.TempDir()
+	j, _ := openJournal(root, "clone-gitlab", false, Log)
+	j.setRepos([]gitlabRepo{
+		{dir: root, project: &gitlab.Project{Name: "Api"}},
+	}, false)
+
+	_, pending := openJournal(root, "clone-gitlab", true, Log)
+	require.Nil(t, pending)
+}
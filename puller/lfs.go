@@ -0,0 +1,535 @@
+package puller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.uber.org/zap"
+)
+
+const (
+	lfsMediaType   = "application/vnd.git-lfs+json"
+	lfsPointerSpec = "version https://git-lfs.github.com/spec/v1"
+
+	// pointer file is always smaller than this, bigger blob is not read
+	lfsPointerMaxSize = 1024
+
+	// number of objects asked in a single batch request
+	lfsBatchSize = 100
+)
+
+var (
+	ErrLFSEndpointNotFound = errors.New("LFS endpoint not found, set lfs.url or use http remote")
+	ErrLFSObjectCorrupted  = errors.New("LFS object doesn't match its pointer")
+	ErrLFSOidNotValid      = errors.New("LFS object id is not a sha256 hash")
+)
+
+// Object id is the hex sha256 of the content, anything else could escape the LFS cache
+var lfsOid = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// LFSResult is the result of fetching the Git LFS objects of the repository
+type LFSResult struct {
+	// Number of file smudged in the worktree, the object is
+	// downloaded or copied from the local LFS cache
+	Files int
+
+	// Number of bytes downloaded from the LFS server
+	Bytes int64
+
+	// Nil when every LFS file is smudged
+	Err error
+}
+
+// lfsPointer is the content of an LFS file committed to the repository
+type lfsPointer struct {
+	Oid  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// lfsFile is a worktree file still containing its pointer
+type lfsFile struct {
+	path    string
+	mode    os.FileMode
+	pointer lfsPointer
+}
+
+// lfsHTTPError is a failed response of the LFS server
+type lfsHTTPError struct {
+	code int
+	msg  string
+}
+
+func (e *lfsHTTPError) Error() string {
+	return fmt.Sprintf("LFS server responded %v: %v", e.code, e.msg)
+}
+
+type lfsBatchRequest struct {
+	Operation string       `json:"operation"`
+	Transfers []string     `json:"transfers"`
+	Objects   []lfsPointer `json:"objects"`
+}
+
+type lfsBatchResponse struct {
+	Objects []lfsBatchObject `json:"objects"`
+}
+
+type lfsBatchObject struct {
+	Oid     string `json:"oid"`
+	Size    int64  `json:"size"`
+	Actions struct {
+		Download *lfsAction `json:"download"`
+	} `json:"actions"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+// lfsClient download the objects of a repository using the basic transfer of the batch API.
+// The credential belong to the host of the origin, it's never sent to another host.
+type lfsClient struct {
+	endpoint string
+	origin   string
+	auth     *Auth
+	client   *http.Client
+	retry    RetryPolicy
+	log      *zap.Logger
+}
+
+// Replace the LFS pointer inside the worktree with the content of its object.
+// The file is detected by the filter=lfs attribute of the committed .gitattributes,
+// so repository without LFS return nil right away. Downloaded object is kept
+// on .git/lfs/objects like git-lfs does, so it's not downloaded twice.
+func fetchLFS(ctx context.Context, log *zap.Logger, retry RetryPolicy, path string, auth *Auth) *LFSResult {
+	log = log.With(zap.String("repo", path), zap.String("action", PhaseLFS))
+
+	repo, err := openRepo(path)
+	if err != nil {
+		return &LFSResult{Err: err}
+	}
+
+	files, err := lfsPointerFiles(repo, path)
+	if err != nil {
+		return &LFSResult{Err: err}
+	}
+	if files == nil {
+		return nil
+	}
+
+	res := &LFSResult{}
+	if len(files) == 0 {
+		return res
+	}
+
+	start := time.Now()
+	missing := make([]lfsFile, 0)
+	for _, f := range files {
+		object, err := lfsObjectPath(path, f.pointer.Oid)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		if _, err := os.Stat(object); err != nil {
+			missing = append(missing, f)
+		}
+	}
+
+	if len(missing) > 0 {
+		endpoint, err := lfsEndpoint(repo, path)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+
+		client := &lfsClient{endpoint: endpoint, origin: remoteURL(path), auth: auth, client: &http.Client{}, retry: retry, log: log}
+		res.Bytes, err = client.download(ctx, path, missing)
+		if err != nil {
+			res.Err = err
+		}
+	}
+
+	// Smudge every file whose object is present, even when some download failed
+	for _, f := range files {
+		if err := smudgeLFSFile(path, f); err != nil {
+			if res.Err == nil {
+				res.Err = err
+			}
+			continue
+		}
+		res.Files++
+	}
+
+	log.Debug("Finish fetching LFS objects", zap.Int("files", res.Files), zap.Int64("bytes", res.Bytes),
+		zap.Duration("duration", time.Since(start)), zap.Error(res.Err))
+	return res
+}
+
+// Return the worktree file having filter=lfs attribute and still containing
+// its pointer. Nil is returned when no .gitattributes use the LFS filter.
+func lfsPointerFiles(repo *git.Repository, path string) ([]lfsFile, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	// Patterns of the parent directory come first, so the deeper one win
+	var attrs []gitattributes.MatchAttribute
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if filepath.Base(f.Name) != ".gitattributes" {
+			return nil
+		}
+
+		content, err := f.Contents()
+		if err != nil || !strings.Contains(content, "filter=lfs") {
+			return err
+		}
+
+		var domain []string
+		if dir := filepath.Dir(f.Name); dir != "." {
+			domain = strings.Split(dir, "/")
+		}
+		patterns, err := gitattributes.ReadAttributes(strings.NewReader(content), domain, domain == nil)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, patterns...)
+		return nil
+	})
+	if err != nil || attrs == nil {
+		return nil, err
+	}
+
+	matcher := gitattributes.NewMatcher(attrs)
+	files := make([]lfsFile, 0)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Size > lfsPointerMaxSize {
+			return nil
+		}
+
+		res, _ := matcher.Match(strings.Split(f.Name, "/"), []string{"filter"})
+		if filter, ok := res["filter"]; !ok || filter.Value() != "lfs" {
+			return nil
+		}
+
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		pointer, ok := parseLFSPointer(content)
+		if !ok {
+			return nil
+		}
+
+		// File being changed locally or already smudged is left untouched
+		local, err := os.ReadFile(filepath.Join(path, f.Name))
+		if err != nil || string(local) != content {
+			return nil
+		}
+
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+		files = append(files, lfsFile{path: f.Name, mode: mode, pointer: pointer})
+		return nil
+	})
+	return files, err
+}
+
+// Parse the pointer file, ex:
+//
+//	version https://git-lfs.github.com/spec/v1
+//	oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+//	size 12345
+func parseLFSPointer(content string) (lfsPointer, bool) {
+	var pointer lfsPointer
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for i := 0; scanner.Scan(); i++ {
+		key, value := scanner.Text(), ""
+		if sep := strings.Index(key, " "); sep >= 0 {
+			key, value = key[:sep], key[sep+1:]
+		}
+
+		switch {
+		case i == 0:
+			if scanner.Text() != lfsPointerSpec {
+				return pointer, false
+			}
+		case key == "oid":
+			pointer.Oid = strings.TrimPrefix(value, "sha256:")
+		case key == "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return pointer, false
+			}
+			pointer.Size = size
+		}
+	}
+
+	if !lfsOid.MatchString(pointer.Oid) {
+		return pointer, false
+	}
+	return pointer, true
+}
+
+// Return the LFS server url using the same order as git-lfs:
+// lfs.url of .lfsconfig, lfs.url of the repository config, then <origin url>.git/info/lfs
+func lfsEndpoint(repo *git.Repository, path string) (string, error) {
+	if f, err := os.Open(filepath.Join(path, ".lfsconfig")); err == nil {
+		defer f.Close()
+
+		cfg := config.New()
+		if err := config.NewDecoder(f).Decode(cfg); err == nil {
+			if url := cfg.Section("lfs").Option("url"); url != "" {
+				return strings.TrimSuffix(url, "/"), nil
+			}
+		}
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", err
+	}
+	if url := cfg.Raw.Section("lfs").Option("url"); url != "" {
+		return strings.TrimSuffix(url, "/"), nil
+	}
+
+	url := remoteURL(path)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", ErrLFSEndpointNotFound
+	}
+
+	url = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(url, ".git") {
+		url += ".git"
+	}
+	return url + "/info/lfs", nil
+}
+
+// Location of the object inside the local LFS cache
+func lfsObjectPath(path string, oid string) (string, error) {
+	if !lfsOid.MatchString(oid) {
+		return "", fmt.Errorf("%w: %q", ErrLFSOidNotValid, oid)
+	}
+	return filepath.Join(path, ".git", "lfs", "objects", oid[0:2], oid[2:4], oid), nil
+}
+
+// Replace the pointer with the content of the object from the local LFS cache
+func smudgeLFSFile(path string, f lfsFile) error {
+	object, err := lfsObjectPath(path, f.pointer.Oid)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(object)
+	if err != nil {
+		return fmt.Errorf("LFS object of %v: %w", f.path, err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filepath.Join(path, f.path), os.O_WRONLY|os.O_TRUNC, f.mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Download the objects of the files into the local LFS cache, asking the
+// batch API for the download action of a chunk of objects at a time.
+// Every object is still tried when one of them failed, the first error is returned.
+func (c *lfsClient) download(ctx context.Context, path string, files []lfsFile) (int64, error) {
+	var (
+		total    int64
+		firstErr error
+	)
+
+	seen := make(map[string]struct{})
+	pointers := make([]lfsPointer, 0, len(files))
+	for _, f := range files {
+		if _, ok := seen[f.pointer.Oid]; !ok {
+			seen[f.pointer.Oid] = struct{}{}
+			pointers = append(pointers, f.pointer)
+		}
+	}
+
+	for i := 0; i < len(pointers); i += lfsBatchSize {
+		end := i + lfsBatchSize
+		if end > len(pointers) {
+			end = len(pointers)
+		}
+
+		var batch *lfsBatchResponse
+		_, err := c.retry.do(ctx, c.log, "lfs batch", func() error {
+			var err error
+			batch, err = c.batch(ctx, pointers[i:end])
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		for _, obj := range batch.Objects {
+			if ctx.Err() != nil {
+				return total, ctx.Err()
+			}
+
+			var err error
+			switch {
+			case obj.Error != nil:
+				err = &lfsHTTPError{code: obj.Error.Code, msg: obj.Error.Message}
+			case obj.Actions.Download == nil:
+				err = fmt.Errorf("LFS server gave no download action for %v", obj.Oid)
+			default:
+				_, err = c.retry.do(ctx, c.log, "lfs download", func() error {
+					return c.get(ctx, path, obj, obj.Actions.Download)
+				})
+			}
+
+			if err != nil {
+				c.log.Warn("Failed to download LFS object", zap.String("oid", obj.Oid), zap.Error(err))
+				if firstErr == nil {
+					firstErr = fmt.Errorf("LFS object %v: %w", obj.Oid, err)
+				}
+				continue
+			}
+			total += obj.Size
+		}
+	}
+	return total, firstErr
+}
+
+// Ask the batch API for the download action of the objects
+func (c *lfsClient) batch(ctx context.Context, pointers []lfsPointer) (*lfsBatchResponse, error) {
+	body, err := json.Marshal(lfsBatchRequest{Operation: "download", Transfers: []string{"basic"}, Objects: pointers})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	c.setAuth(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &lfsHTTPError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+
+	batch := &lfsBatchResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// Set the credential on the request going to the host of the origin,
+// request to another host (lfs.url, storage of the download action) is sent without it
+func (c *lfsClient) setAuth(req *http.Request) {
+	if c.auth == nil || c.origin == "" || !sameHost(req.URL.String(), c.origin) {
+		return
+	}
+	req.SetBasicAuth(c.auth.Username, c.auth.Password)
+}
+
+// Download the object into the local LFS cache, verifying its size and hash.
+// The object is written into a temporary file first, so a failed download never
+// leave a partial object on the cache.
+func (c *lfsClient) get(ctx context.Context, path string, obj lfsBatchObject, action *lfsAction) error {
+	target, err := lfsObjectPath(path, obj.Oid)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, action.Href, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range action.Header {
+		req.Header.Set(key, value)
+	}
+	if req.Header.Get("Authorization") == "" {
+		c.setAuth(req)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &lfsHTTPError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), obj.Oid+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if size != obj.Size || hex.EncodeToString(hash.Sum(nil)) != obj.Oid {
+		return ErrLFSObjectCorrupted
+	}
+	return os.Rename(tmp.Name(), target)
+}
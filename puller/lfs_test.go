@@ -0,0 +1,267 @@
+package puller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLFS is a stand-in of the LFS server serving the batch API
+// and the objects from memory. The download action carry its own
+// authorization header, like the storage url given by a real server.
+type fakeLFS struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	objects   map[string][]byte
+	downloads int
+
+	// authorization header of every request
+	authorizations []string
+}
+
+func newFakeLFS(t *testing.T) *fakeLFS {
+	f := &fakeLFS{objects: make(map[string][]byte)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// Store the object and return its pointer file
+func (f *fakeLFS) add(content string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(content))
+	oid := hex.EncodeToString(sum[:])
+	f.objects[oid] = []byte(content)
+	return lfsPointerContent(oid, len(content))
+}
+
+func lfsPointerContent(oid string, size int) string {
+	return fmt.Sprintf("%v\noid sha256:%v\nsize %v\n", lfsPointerSpec, oid, size)
+}
+
+func (f *fakeLFS) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.authorizations = append(f.authorizations, r.Header.Get("Authorization"))
+
+	if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/objects/") {
+		oid := strings.TrimPrefix(r.URL.Path, "/objects/")
+		if r.Header.Get("Authorization") != "RemoteAuth "+oid {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		content, ok := f.objects[oid]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		f.downloads++
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.Method != http.MethodPost || r.URL.Path != "/objects/batch" || r.Header.Get("Accept") != lfsMediaType {
+		http.NotFound(w, r)
+		return
+	}
+
+	req := lfsBatchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Operation != "download" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	resp := lfsBatchResponse{}
+	for _, p := range req.Objects {
+		obj := lfsBatchObject{Oid: p.Oid, Size: p.Size}
+		if _, ok := f.objects[p.Oid]; ok {
+			obj.Actions.Download = &lfsAction{
+				Href:   f.server.URL + "/objects/" + p.Oid,
+				Header: map[string]string{"Authorization": "RemoteAuth " + p.Oid},
+			}
+		} else {
+			obj.Error = &struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}{Code: http.StatusNotFound, Message: "Object does not exist"}
+		}
+		resp.Objects = append(resp.Objects, obj)
+	}
+
+	w.Header().Set("Content-Type", lfsMediaType)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	oid := strings.Repeat("ab", 32)
+	tests := []struct {
+		name    string
+		content string
+		want    lfsPointer
+		ok      bool
+	}{
+		{"Pointer", lfsPointerContent(oid, 12), lfsPointer{Oid: oid, Size: 12}, true},
+		{"Extension Line", lfsPointerSpec + "\next-0-foo sha256:" + oid + "\noid sha256:" + oid + "\nsize 3\n", lfsPointer{Oid: oid, Size: 3}, true},
+		{"Regular File", "hello world\n", lfsPointer{}, false},
+		{"Short Oid", lfsPointerContent("abc", 12), lfsPointer{}, false},
+		{"Upper Case Oid", lfsPointerContent(strings.ToUpper(oid), 12), lfsPointer{}, false},
+		{"Path Traversal Oid", lfsPointerContent("../../../../"+oid[12:], 12), lfsPointer{}, false},
+		{"Invalid Size", lfsPointerSpec + "\noid sha256:" + oid + "\nsize big\n", lfsPointer{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLFSPointer(tt.content)
+			require.Equal(t, tt.ok, ok)
+			if tt.ok {
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLFSObjectPath(t *testing.T) {
+	oid := strings.Repeat("ab", 32)
+	path, err := lfsObjectPath("/repo", oid)
+	require.Nil(t, err)
+	require.Equal(t, "/repo/.git/lfs/objects/ab/ab/"+oid, path)
+
+	for _, oid := range []string{"", "abc", "../../../../etc/" + oid[16:], strings.ToUpper(oid), oid + "0"} {
+		_, err := lfsObjectPath("/repo", oid)
+		require.ErrorIs(t, err, ErrLFSOidNotValid, oid)
+	}
+}
+
+func TestLFSCredentialHost(t *testing.T) {
+	gitlab := newFakeLFS(t)
+	storage := newFakeLFS(t)
+	content := "model weights"
+	pointer, ok := parseLFSPointer(storage.add(content))
+	require.True(t, ok)
+
+	// The batch API of gitlab give a download action on another host
+	batch := func(w http.ResponseWriter, r *http.Request) {
+		gitlab.mu.Lock()
+		gitlab.authorizations = append(gitlab.authorizations, r.Header.Get("Authorization"))
+		gitlab.mu.Unlock()
+
+		resp := lfsBatchResponse{Objects: []lfsBatchObject{{Oid: pointer.Oid, Size: pointer.Size}}}
+		resp.Objects[0].Actions.Download = &lfsAction{
+			Href:   storage.server.URL + "/objects/" + pointer.Oid,
+			Header: map[string]string{"Authorization": "RemoteAuth " + pointer.Oid},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+	gitlab.server.Config.Handler = http.HandlerFunc(batch)
+
+	path := t.TempDir()
+	client := &lfsClient{
+		endpoint: gitlab.server.URL + "/group/assets.git/info/lfs",
+		origin:   gitlab.server.URL + "/group/assets.git",
+		auth:     &Auth{Username: "token", Password: "secret"},
+		client:   &http.Client{},
+		retry:    RetryPolicy{MaxAttempts: 1},
+		log:      Log,
+	}
+
+	size, err := client.download(context.Background(), path, []lfsFile{{path: "model.bin", pointer: pointer}})
+	require.Nil(t, err)
+	require.Equal(t, int64(len(content)), size)
+
+	// Credential go to the gitlab host only, the storage get the header of the action
+	require.Len(t, gitlab.authorizations, 1)
+	require.True(t, strings.HasPrefix(gitlab.authorizations[0], "Basic "))
+	require.Equal(t, []string{"RemoteAuth " + pointer.Oid}, storage.authorizations)
+
+	// lfs.url on another host than the origin doesn't get the credential either
+	client.origin = "https://gitlab.example.com/group/assets.git"
+	require.Nil(t, os.RemoveAll(path+"/.git"))
+	_, err = client.download(context.Background(), path, []lfsFile{{path: "model.bin", pointer: pointer}})
+	require.Nil(t, err)
+	require.Equal(t, "", gitlab.authorizations[1])
+}
+
+func TestLFS(t *testing.T) {
+	ws := newTestWorkspace(t)
+	lfs := newFakeLFS(t)
+
+	assets := newTestRemote(t, ws.dir, "assets")
+	commitTestFile(t, assets.work, ".gitattributes", "*.bin filter=lfs diff=lfs merge=lfs -text\n")
+	commitTestFile(t, assets.work, ".lfsconfig", "[lfs]\n\turl = "+lfs.server.URL+"\n")
+	commitTestFile(t, assets.work, "model.bin", lfs.add("model weights"))
+	commitTestFile(t, assets.work, "lost.bin", lfsPointerContent(strings.Repeat("0", 64), 10))
+	assets.commit(t, "notes.txt", lfsPointerContent(strings.Repeat("1", 64), 10))
+	ws.gitlab.addProject(1, "assets", assets.url)
+
+	lfsResults := func(res *Result) map[string]*LFSResult {
+		results := make(map[string]*LFSResult)
+		for _, repo := range res.Repos {
+			results[strings.TrimPrefix(repo.Path, ws.root+"/")] = repo.LFS
+		}
+		return results
+	}
+
+	s, _ := ws.syncer(t, Options{LFS: true})
+	res, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+
+	// Missing object is reported without failing the repository
+	require.Equal(t, StatusSuccess, statusByPath(ws.root, res)["Team/assets"])
+	result := lfsResults(res)["Team/assets"]
+	require.Equal(t, 1, result.Files)
+	require.Equal(t, int64(len("model weights")), result.Bytes)
+	require.NotNil(t, result.Err)
+	require.True(t, strings.Contains(result.Err.Error(), "Object does not exist"))
+
+	content, err := os.ReadFile(ws.root + "/Team/assets/model.bin")
+	require.Nil(t, err)
+	require.Equal(t, "model weights", string(content))
+
+	// Pointer outside the LFS pattern is not smudged
+	content, err = os.ReadFile(ws.root + "/Team/assets/notes.txt")
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(string(content), lfsPointerSpec))
+
+	// Repository without LFS has nothing to report
+	require.Nil(t, lfsResults(res)["Team/api"])
+
+	// The lfs.url is not on the host of the origin, the credential is never sent to it
+	for _, authorization := range lfs.authorizations {
+		require.False(t, strings.HasPrefix(authorization, "Basic "), authorization)
+	}
+
+	// New LFS file is smudged after the pull, the cached object is not downloaded again
+	commitTestFile(t, assets.work, "lost.bin", lfs.add("found again"))
+	assets.commit(t, "copy.bin", lfs.add("model weights"))
+
+	res, err = s.UpdateGitlab(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, StatusSuccess, statusByPath(ws.root, res)["Team/assets"])
+	result = lfsResults(res)["Team/assets"]
+	require.Nil(t, result.Err)
+	require.Equal(t, int64(len("found again")), result.Bytes)
+	require.Equal(t, 2, lfs.downloads)
+
+	// The pull checkout the pointer of model.bin again, it's smudged from the cache
+	require.Equal(t, 3, result.Files)
+
+	for name, want := range map[string]string{"model.bin": "model weights", "lost.bin": "found again", "copy.bin": "model weights"} {
+		content, err := os.ReadFile(ws.root + "/Team/assets/" + name)
+		require.Nil(t, err)
+		require.Equal(t, want, string(content))
+	}
+}
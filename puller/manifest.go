@@ -0,0 +1,307 @@
+package puller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrManifestNotSet       = errors.New("Manifest file has not been set")
+	ErrManifestRepoSource   = errors.New("Manifest repository must have either url or gitlab")
+	ErrManifestPathNotValid = errors.New("Manifest repository path must be relative to the workspace")
+	ErrManifestPathConflict = errors.New("Manifest repository path is used twice")
+	ErrManifestPathNotRepo  = errors.New("Manifest repository path exists but is not a git repository")
+)
+
+// Manifest declare a workspace of repositories, read from YAML (or JSON) file
+type Manifest struct {
+	// Value used by every repository that doesn't set it
+	Defaults ManifestDefaults `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+
+	Repos []ManifestRepo `yaml:"repos" json:"repos"`
+}
+
+// ManifestDefaults is the default value of the manifest repositories
+type ManifestDefaults struct {
+	// Base url of the gitlab path, the url option is used when it's not set
+	Gitlab string `yaml:"gitlab,omitempty" json:"gitlab,omitempty"`
+
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Depth  int    `yaml:"depth,omitempty" json:"depth,omitempty"`
+	Remote string `yaml:"remote,omitempty" json:"remote,omitempty"`
+}
+
+// ManifestRepo is a repository of the workspace
+type ManifestRepo struct {
+	// Directory of the repository relative to the workspace. By default it's
+	// the gitlab path, or the name of the repository in the url
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Url of the repository, or its path on gitlab (ex: team/api), only one of them is set
+	URL    string `yaml:"url,omitempty" json:"url,omitempty"`
+	Gitlab string `yaml:"gitlab,omitempty" json:"gitlab,omitempty"`
+
+	// Branch being cloned and updated, master (or main) by default
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+
+	// Number of commit being cloned, zero clone the full history.
+	// Shallow repository can't be updated, so it's only fit for read only checkout.
+	Depth int `yaml:"depth,omitempty" json:"depth,omitempty"`
+
+	// Name of the remote being cloned and pulled from, origin by default
+	Remote string `yaml:"remote,omitempty" json:"remote,omitempty"`
+
+	// Commit the branch is reset to after it's cloned, so the workspace is reproduced
+	// exactly. Existing repository is updated as usual, the commit is ignored.
+	Commit string `yaml:"commit,omitempty" json:"commit,omitempty"`
+}
+
+// manifestEntry is the manifest repository with its default applied
+type manifestEntry struct {
+	path string
+	spec cloneSpec
+
+	// Set when the url is on the gitlab host, only then the credential is sent
+	credential bool
+}
+
+// Read the manifest file, unknown field is rejected so typo doesn't go unnoticed
+func loadManifest(file string) (*Manifest, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(manifest); err != nil {
+		return nil, fmt.Errorf("manifest %v: %w", file, err)
+	}
+	return manifest, nil
+}
+
+// Apply the default value into every repository of the manifest.
+// Gitlab path is resolved against the base url.
+func (m *Manifest) entries(baseurl string) ([]manifestEntry, error) {
+	if m.Defaults.Gitlab != "" {
+		baseurl = m.Defaults.Gitlab
+	}
+
+	entries := make([]manifestEntry, 0, len(m.Repos))
+	paths := make(map[string]struct{})
+	for i, repo := range m.Repos {
+		if (repo.URL == "") == (repo.Gitlab == "") {
+			return nil, fmt.Errorf("%w: repos[%v]", ErrManifestRepoSource, i)
+		}
+
+		url, dir := repo.URL, repo.Path
+		if repo.Gitlab != "" {
+			gitlabPath := strings.Trim(repo.Gitlab, "/")
+			url = strings.TrimSuffix(baseurl, "/") + "/" + gitlabPath + ".git"
+			if dir == "" {
+				dir = gitlabPath
+			}
+		}
+		if dir == "" {
+			dir = strings.TrimSuffix(path.Base(strings.TrimSuffix(url, "/")), ".git")
+		}
+
+		dir = path.Clean(strings.ReplaceAll(dir, "\\", "/"))
+		if path.IsAbs(dir) || dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("%w: %v", ErrManifestPathNotValid, dir)
+		}
+		if _, ok := paths[dir]; ok {
+			return nil, fmt.Errorf("%w: %v", ErrManifestPathConflict, dir)
+		}
+		paths[dir] = struct{}{}
+
+		spec := cloneSpec{
+			url:    url,
+			depth:  firstInt(repo.Depth, m.Defaults.Depth),
+			remote: firstString(repo.Remote, m.Defaults.Remote),
+			commit: repo.Commit,
+		}
+		if branch := firstString(repo.Branch, m.Defaults.Branch); branch != "" {
+			spec.branch = plumbing.NewBranchReferenceName(branch)
+		}
+		entries = append(entries, manifestEntry{path: dir, spec: spec, credential: baseurl != "" && sameHost(url, baseurl)})
+	}
+	return entries, nil
+}
+
+func firstString(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstInt(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// Make the directory match the manifest: missing repository is cloned, existing one
+// is updated and repository that isn't in the manifest is reported, never removed.
+// The same clone and update as the gitlab actions is used, by a pool of workers.
+func (c *engine) SyncManifest(ctx context.Context) error {
+	start := time.Now()
+	c.log.Debug("Start syncing manifest", zap.String("action", "sync-manifest"), zap.String("manifest", c.manifest))
+	c.startRun()
+
+	manifest, err := loadManifest(c.manifest)
+	if err != nil {
+		return err
+	}
+	entries, err := manifest.entries(c.baseurl)
+	if err != nil {
+		return err
+	}
+	c.events.discoveryFinished(len(entries))
+
+	workers := c.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan manifestEntry)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				_ = c.syncManifestRepo(ctx, entry)
+			}
+		}()
+	}
+
+	for _, entry := range entries {
+		// Stop scheduling new repository when the run is canceled
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- entry
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	extras, err := c.manifestExtras(ctx, entries)
+	if err != nil {
+		return err
+	}
+	c.report.setExtras(extras)
+
+	c.log.Debug("Finish syncing manifest", zap.String("action", "sync-manifest"), zap.Int("repos", len(entries)),
+		zap.Int("extras", len(extras)), zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// Clone the repository of the manifest when it doesn't exist, otherwise update it.
+// Path holding something else than a repository is failed and left untouched.
+// Repository on another host than gitlab is accessed anonymously, the token is only for gitlab.
+func (c *engine) syncManifestRepo(ctx context.Context, entry manifestEntry) error {
+	dir := c.dir + "/" + entry.path
+	auth := c.auth
+	if !entry.credential {
+		auth = nil
+	}
+	if isRepo(dir) {
+		if url := remoteURL(dir); url != entry.spec.url && entry.spec.remote == "" {
+			c.log.Warn("Repository url is not the one of the manifest", zap.String("repo", dir), zap.String("url", url),
+				zap.String("manifest_url", entry.spec.url))
+		}
+
+		node := makeNode(&nodeOptions{
+			path:       dir,
+			hardReset:  c.hardReset,
+			events:     c.events,
+			log:        c.log,
+			auth:       auth,
+			retry:      c.retry,
+			hooks:      c.hooks,
+			submodules: c.submodules,
+			lfs:        c.lfs,
+
+			allRemotes:  c.allRemotes,
+			pushOrigin:  c.pushOrigin,
+			allBranches: c.allBranches,
+			divergence:  c.divergence,
+			branch:      entry.spec.branch.Short(),
+			remote:      entry.spec.remote,
+
+			repoTimeout: c.repoTimeout,
+		})
+		return node.updateRepo(ctx)
+	}
+
+	if _, err := os.Lstat(dir); err == nil {
+		err = fmt.Errorf("%w: %v", ErrManifestPathNotRepo, dir)
+		c.events.finished(RepoResult{
+			Path:   dir,
+			Action: ActionClone,
+			Status: StatusFailed,
+			Err:    err,
+		})
+		return err
+	}
+
+	node := &nodeGitlab{
+		events:     c.events,
+		log:        c.log,
+		auth:       auth,
+		retry:      c.retry,
+		hooks:      c.hooks,
+		submodules: c.submodules,
+		lfs:        c.lfs,
+
+		repoTimeout: c.repoTimeout,
+	}
+	return node.clone(ctx, dir, entry.path, entry.spec)
+}
+
+// Return the repositories inside the directory that are not in the manifest
+func (c *engine) manifestExtras(ctx context.Context, entries []manifestEntry) ([]string, error) {
+	declared := make(map[string]struct{})
+	for _, entry := range entries {
+		declared[c.dir+"/"+entry.path] = struct{}{}
+	}
+
+	extras := make([]string, 0)
+	err := makeNode(&nodeOptions{
+		path:       c.dir,
+		exGroups:   c.exGroups,
+		exProjects: c.exProjects,
+		maxDepth:   c.maxDepth,
+		log:        c.log,
+	}).walkRepos(ctx, func(repo *node) {
+		if _, ok := declared[repo.path]; !ok {
+			extras = append(extras, repo.path)
+		}
+	})
+	sort.Strings(extras)
+	return extras, err
+}
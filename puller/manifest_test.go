@@ -0,0 +1,260 @@
+package puller
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		want     []manifestEntry
+		err      error
+	}{
+		{
+			name: "Defaults",
+			manifest: Manifest{
+				Defaults: ManifestDefaults{Branch: "develop", Depth: 1, Remote: "upstream"},
+				Repos: []ManifestRepo{
+					{Gitlab: "/team/api/"},
+					{URL: "https://github.com/go-git/go-git.git", Branch: "master", Depth: 5, Remote: "origin"},
+					{URL: "https://github.com/uber-go/zap", Path: "third-party\\zap"},
+				},
+			},
+			want: []manifestEntry{
+				{path: "team/api", spec: cloneSpec{url: "https://gitlab.com/team/api.git", branch: "refs/heads/develop", depth: 1, remote: "upstream"}, credential: true},
+				{path: "go-git", spec: cloneSpec{url: "https://github.com/go-git/go-git.git", branch: plumbing.Master, depth: 5, remote: "origin"}},
+				{path: "third-party/zap", spec: cloneSpec{url: "https://github.com/uber-go/zap", branch: "refs/heads/develop", depth: 1, remote: "upstream"}},
+			},
+		},
+		{
+			name: "Gitlab Base Url",
+			manifest: Manifest{
+				Defaults: ManifestDefaults{Gitlab: "http://localhost/"},
+				Repos:    []ManifestRepo{{Gitlab: "team/api", Path: "api"}},
+			},
+			want: []manifestEntry{{path: "api", spec: cloneSpec{url: "http://localhost/team/api.git"}, credential: true}},
+		},
+		{
+			name: "Credential Only On Gitlab Host",
+			manifest: Manifest{
+				Defaults: ManifestDefaults{Gitlab: "http://localhost/"},
+				Repos: []ManifestRepo{
+					{URL: "http://localhost/team/web.git"},
+					{URL: "https://gitlab.com/team/app.git"},
+					{URL: "git@localhost:team/lib.git"},
+				},
+			},
+			want: []manifestEntry{
+				{path: "web", spec: cloneSpec{url: "http://localhost/team/web.git"}, credential: true},
+				{path: "app", spec: cloneSpec{url: "https://gitlab.com/team/app.git"}},
+				{path: "lib", spec: cloneSpec{url: "git@localhost:team/lib.git"}},
+			},
+		},
+		{"Without Source", Manifest{Repos: []ManifestRepo{{Path: "api"}}}, nil, ErrManifestRepoSource},
+		{"Both Source", Manifest{Repos: []ManifestRepo{{URL: "https://gitlab.com/api.git", Gitlab: "api"}}}, nil, ErrManifestRepoSource},
+		{"Absolute Path", Manifest{Repos: []ManifestRepo{{Gitlab: "api", Path: "/srv/api"}}}, nil, ErrManifestPathNotValid},
+		{"Outside Path", Manifest{Repos: []ManifestRepo{{Gitlab: "api", Path: "team/../../api"}}}, nil, ErrManifestPathNotValid},
+		{"Same Path", Manifest{Repos: []ManifestRepo{{Gitlab: "team/api"}, {URL: "https://github.com/api.git", Path: "team/api"}}}, nil, ErrManifestPathConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := tt.manifest.entries("https://gitlab.com/")
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.want, entries)
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    *Manifest
+		err     bool
+	}{
+		{"YAML", "defaults:\n  branch: main\nrepos:\n  - gitlab: team/api\n    depth: 1\n", &Manifest{
+			Defaults: ManifestDefaults{Branch: "main"},
+			Repos:    []ManifestRepo{{Gitlab: "team/api", Depth: 1}},
+		}, false},
+		{"JSON", `{"repos": [{"url": "https://gitlab.com/api.git", "path": "api"}]}`, &Manifest{
+			Repos: []ManifestRepo{{URL: "https://gitlab.com/api.git", Path: "api"}},
+		}, false},
+		{"Unknown Field", "repos:\n  - gitlab: team/api\n    brnach: main\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := dir + "/" + tt.name
+			require.Nil(t, os.WriteFile(file, []byte(tt.content), 0644))
+
+			manifest, err := loadManifest(file)
+			if tt.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.want, manifest)
+		})
+	}
+}
+
+func TestSyncManifest(t *testing.T) {
+	dir := t.TempDir()
+	api := newTestRemote(t, dir, "api")
+	web := newTestRemote(t, dir, "web")
+	newTestRemote(t, dir, "lib")
+
+	head, err := web.work.Head()
+	require.Nil(t, err)
+	require.Nil(t, web.work.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("develop"), head.Hash())))
+	require.Nil(t, web.work.Push(&git.PushOptions{RemoteName: "bare", RefSpecs: []config.RefSpec{"refs/heads/develop:refs/heads/develop"}}))
+
+	ws := dir + "/workspace"
+	_, err = git.PlainClone(ws+"/api", false, &git.CloneOptions{URL: api.url})
+	require.Nil(t, err)
+	api.commit(t, "main.go", "package main")
+	initTestRepo(t, ws+"/old/tool")
+
+	manifest := dir + "/workspace.yaml"
+	require.Nil(t, os.WriteFile(manifest, []byte(`
+defaults:
+  gitlab: `+dir+`/bare
+repos:
+  - gitlab: api
+  - gitlab: web
+    path: team/web
+    branch: develop
+  - url: `+dir+`/bare/lib.git
+    depth: 1
+    remote: upstream
+`), 0644))
+
+	s, err := NewSyncer(&Options{
+		Dir:      ws,
+		Auth:     &Auth{Username: "token", Password: "token"},
+		Manifest: manifest,
+		Workers:  2,
+		Retry:    &RetryPolicy{MaxAttempts: 1},
+	}, nil)
+	require.Nil(t, err)
+
+	res, err := s.SyncManifest(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"api":      StatusSuccess,
+		"team/web": StatusSuccess,
+		"lib":      StatusSuccess,
+	}, statusByPath(ws, res))
+	require.Equal(t, []string{ws + "/old/tool"}, res.Extras)
+	require.FileExists(t, ws+"/api/main.go")
+
+	webRepo, err := git.PlainOpen(ws + "/team/web")
+	require.Nil(t, err)
+	webHead, err := webRepo.Head()
+	require.Nil(t, err)
+	require.Equal(t, plumbing.NewBranchReferenceName("develop"), webHead.Name())
+
+	libRepo, err := git.PlainOpen(ws + "/lib")
+	require.Nil(t, err)
+	require.True(t, isShallow(libRepo))
+	_, err = libRepo.Remote("upstream")
+	require.Nil(t, err)
+
+	// Nothing changed on the remotes, the shallow repository is up-to-date too
+	res, err = s.SyncManifest(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"api":      StatusUpToDate,
+		"team/web": StatusUpToDate,
+		"lib":      StatusUpToDate,
+	}, statusByPath(ws, res))
+}
+
+// Path holding something else than a repository is failed, never cloned into nor removed
+func TestSyncManifestNotRepo(t *testing.T) {
+	dir := t.TempDir()
+	newTestRemote(t, dir, "api")
+
+	ws := dir + "/workspace"
+	require.Nil(t, os.MkdirAll(ws+"/api", os.ModePerm))
+	require.Nil(t, os.WriteFile(ws+"/api/notes.txt", []byte("user data"), 0644))
+
+	manifest := dir + "/workspace.yaml"
+	require.Nil(t, os.WriteFile(manifest, []byte("repos:\n  - url: "+dir+"/bare/api.git\n"), 0644))
+
+	s, err := NewSyncer(&Options{
+		Dir:      ws,
+		Auth:     &Auth{Username: "token", Password: "token"},
+		Manifest: manifest,
+		Retry:    &RetryPolicy{MaxAttempts: 1},
+	}, nil)
+	require.Nil(t, err)
+
+	res, err := s.SyncManifest(context.Background())
+	require.Nil(t, err)
+	require.Len(t, res.Repos, 1)
+	require.Equal(t, StatusFailed, res.Repos[0].Status)
+	require.ErrorIs(t, res.Repos[0].Err, ErrManifestPathNotRepo)
+
+	content, err := os.ReadFile(ws + "/api/notes.txt")
+	require.Nil(t, err)
+	require.Equal(t, "user data", string(content))
+	require.NoDirExists(t, ws+"/api/.git")
+}
+
+func TestSyncManifestOtherHost(t *testing.T) {
+	dir := t.TempDir()
+	newTestRemote(t, dir, "api")
+	lib := newTestRemote(t, dir, "lib")
+
+	// The gitlab and the other host are two servers (the port is part of the host)
+	gitlabHost := newGitHTTPServer(t, dir+"/bare")
+	otherHost := newGitHTTPServer(t, dir+"/bare")
+
+	manifest := dir + "/workspace.yaml"
+	require.Nil(t, os.WriteFile(manifest, []byte(`
+defaults:
+  gitlab: `+gitlabHost.URL+`
+repos:
+  - gitlab: api
+  - url: `+otherHost.URL+`/lib.git
+`), 0644))
+
+	ws := dir + "/workspace"
+	require.Nil(t, os.MkdirAll(ws, 0755))
+	s, err := NewSyncer(&Options{
+		Dir:      ws,
+		Auth:     &Auth{Username: "token", Password: "token"},
+		Manifest: manifest,
+		Retry:    &RetryPolicy{MaxAttempts: 1},
+	}, nil)
+	require.Nil(t, err)
+
+	// Cloned then updated, the token is never sent to the other host
+	res, err := s.SyncManifest(context.Background())
+	require.Nil(t, err)
+	require.Empty(t, res.Failed())
+	lib.commit(t, "main.go", "package main")
+	res, err = s.SyncManifest(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"api": StatusUpToDate, "lib": StatusSuccess}, statusByPath(ws, res))
+
+	require.NotEmpty(t, otherHost.authorizations())
+	for _, auth := range otherHost.authorizations() {
+		require.Empty(t, auth)
+	}
+	require.NotEmpty(t, gitlabHost.authorizations())
+	for _, auth := range gitlabHost.authorizations() {
+		require.NotEmpty(t, auth)
+	}
+}
@@ -0,0 +1,330 @@
+// Package puller clone and update the git repositories of a directory or of a gitlab
+// group tree. It never prints anything, the result of every repository is returned
+// by the Syncer and every event of the run is delivered to the observers.
+package puller
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// Flag for activating logging while the program running,
+	// the command line doesn't show the progress display then
+	Verbose bool
+
+	// Define action to be executed in command
+	Action string
+
+	// Define root directory of the action
+	Dir string
+
+	// Exclude Group name, separated by commas
+	Exgroups []string
+
+	// Exclude Project name, separated by commas
+	Exprojects []string
+
+	// How deep the directory is walked looking for repository (update, exec and restore),
+	// zero means no limit
+	MaxDepth int
+
+	// a flag that used to make decision wether it's need to be hard reset
+	// before the pull being executed
+	Hardreset bool
+
+	// Define authentication used for interacting with git repository
+	Auth *Auth
+
+	// Define base url (usefull for update-gitlab or clone project)
+	Baseurl string
+
+	// Set the zap logger
+	Logs *zap.Logger
+
+	// Include projects inside the authenticated user's personal namespace
+	// (only used by gitlab actions)
+	Owned bool
+
+	// Include projects that are shared into a group from another namespace
+	WithShared bool
+
+	// Only sync projects the authenticated user is a member of, listed directly
+	// instead of walking the group tree
+	MembershipOnly bool
+
+	// Maximum gitlab api requests per second, zero means no fixed limit.
+	// The RateLimit-* and Retry-After header from the server are always honored.
+	RateLimit float64
+
+	// Retry policy for clone, fetch and pull. DefaultRetryPolicy is used when it's not set
+	Retry *RetryPolicy
+
+	// Maximum duration of the whole run, zero means no limit
+	Timeout time.Duration
+
+	// Maximum duration of a single clone/pull (retry included), zero means no limit.
+	// Timed out repository is reported as failure.
+	RepoTimeout time.Duration
+
+	// Continue the previous gitlab run from its journal,
+	// skipping the enumeration and the finished repositories
+	Resume bool
+
+	// Number of repository being cloned/updated at the same time
+	Workers int
+
+	// Skip gitlab repository that has no activity on the server and
+	// no local commit since the previous run (update-gitlab only)
+	Incremental bool
+
+	// Commands run inside every repository before/after it's cloned or updated
+	Hooks Hooks
+
+	// Number of commit cloned by the gitlab actions, zero clone the full history.
+	// The command line default to 1. Shallow repository can't be updated afterward.
+	Depth int
+
+	// Initialize and update the submodules recursively after clone and pull
+	Submodules bool
+
+	// Download the Git LFS objects and smudge the LFS files after clone and pull
+	LFS bool
+
+	// Fetch every remote of the repository and pull the branch from
+	// the upstream remote when it's present (a fork), instead of origin
+	AllRemotes bool
+
+	// Push the branch pulled from upstream into origin, only used with AllRemotes
+	PushOrigin bool
+
+	// Fetch once and fast-forward every local branch tracking a remote branch,
+	// without checking it out
+	AllBranches bool
+
+	// Strategy applied on the checked out branch diverged from its remote branch:
+	// DivergenceSkip (default), DivergenceMerge or DivergenceRebase
+	Divergence string
+
+	// Command and its arguments run inside every repository by exec action
+	ExecArgs []string
+
+	// Manifest file declaring the repositories of the workspace (sync-manifest),
+	// or written by the export action of the command line, which print it when it's empty.
+	Manifest string
+
+	// What export action walk: ExportLocal (default) for the directory,
+	// ExportGitlab for the gitlab group tree
+	ExportFrom string
+
+	// Backup restored by the restore action of the command line, BackupLatest for the newest
+	// backup of every repository. The backups are listed when it's empty. Not used by the Syncer.
+	Backup string
+
+	// Receive every event of the run along with the logger and the report
+	Observers []Observer
+}
+
+// engine clone and update the repositories, it never prints anything.
+// The result of the run is collected by its report.
+type engine struct {
+	dir        string
+	exGroups   map[string]struct{}
+	exProjects map[string]struct{}
+	maxDepth   int
+	auth       *Auth
+	hardReset  bool
+	baseurl    string
+
+	// gitlab project listing options
+	owned          bool
+	withShared     bool
+	membershipOnly bool
+	rateLimit      float64
+
+	// retry policy of git network operation, the result of the run
+	// and the event bus delivering every event to the observers
+	retry     RetryPolicy
+	report    *report
+	events    *eventBus
+	observers []Observer
+
+	// time limit of the whole run and of every repository
+	timeout     time.Duration
+	repoTimeout time.Duration
+
+	resume      bool
+	workers     int
+	incremental bool
+	hooks       Hooks
+	depth       int
+	submodules  bool
+	lfs         bool
+	allRemotes  bool
+	pushOrigin  bool
+	allBranches bool
+	divergence  string
+	execArgs    []string
+	backup      string
+	manifest    string
+	exportFrom  string
+
+	// default logger for the package command (zap logger)
+	log *zap.Logger
+}
+
+type Auth struct {
+	// Username being used for authentication with git.
+	// If this token was set, then this field will have default value "token" (acording to go-git docs to use like this)
+	Username string
+
+	// Password for authentication with git
+	// If token was set, then it's gonna be put in here
+	Password string
+}
+
+var (
+	ErrCredentialNotFound = errors.New("Credential has not been set completely")
+	ErrLogsNotDefined     = errors.New("Zap logger has not been defined")
+	ErrDirNotExist        = errors.New("Directory not valid/exist")
+	ErrGitNotFound        = errors.New("git binary is required by the merge, the rebase and the restore but not found in PATH")
+)
+
+// Create the engine running the actions of the options
+func newEngine(opt *Options) (*engine, error) {
+
+	if err := validate(opt); err != nil {
+		return nil, err
+	}
+
+	exProject := make(map[string]struct{})
+	for _, val := range opt.Exprojects {
+		exProject[val] = struct{}{}
+	}
+
+	exGroups := make(map[string]struct{})
+	for _, val := range opt.Exgroups {
+		exGroups[val] = struct{}{}
+	}
+
+	c := engine{
+		auth:       opt.Auth,
+		dir:        opt.Dir,
+		exGroups:   exGroups,
+		exProjects: exProject,
+		maxDepth:   opt.MaxDepth,
+		hardReset:  opt.Hardreset,
+		baseurl:    opt.Baseurl,
+		log:        opt.Logs,
+
+		owned:          opt.Owned,
+		withShared:     opt.WithShared,
+		membershipOnly: opt.MembershipOnly,
+		rateLimit:      opt.RateLimit,
+		retry:          DefaultRetryPolicy,
+		report:         &report{},
+		timeout:        opt.Timeout,
+		repoTimeout:    opt.RepoTimeout,
+		resume:         opt.Resume,
+		workers:        opt.Workers,
+		incremental:    opt.Incremental,
+		hooks:          opt.Hooks,
+		depth:          opt.Depth,
+		submodules:     opt.Submodules,
+		lfs:            opt.LFS,
+		allRemotes:     opt.AllRemotes,
+		pushOrigin:     opt.PushOrigin,
+		allBranches:    opt.AllBranches,
+		divergence:     opt.Divergence,
+		execArgs:       opt.ExecArgs,
+		backup:         opt.Backup,
+		manifest:       opt.Manifest,
+		exportFrom:     opt.ExportFrom,
+		observers:      opt.Observers,
+	}
+
+	if opt.Retry != nil {
+		c.retry = *opt.Retry
+	}
+
+	return &c, nil
+}
+
+// Validate given options is enough to do the task
+// Credential, action performed, directory and the logs
+func validate(opt *Options) error {
+	if opt == nil {
+		return ErrOptionsNotSet
+	}
+
+	if opt.Action == "version" || opt.Action == "usage" {
+		return nil
+	}
+
+	if match, _ := regexp.MatchString(`[/\\]{2,}$`, opt.Dir); match {
+		return ErrDirNotExist
+	}
+
+	if strings.HasSuffix(opt.Dir, "\\") || strings.HasSuffix(opt.Dir, "/") {
+		opt.Dir = strings.TrimSuffix(opt.Dir, "/")
+		opt.Dir = strings.TrimSuffix(opt.Dir, "\\")
+	}
+
+	if _, err := os.Stat(opt.Dir); err != nil {
+		return ErrDirNotExist
+	}
+
+	// Exec, restore and the export of the directory only run locally, so they don't need the credential
+	localExport := opt.Action == "export" && opt.ExportFrom != ExportGitlab
+	if opt.Action == "exec" {
+		if len(opt.ExecArgs) == 0 {
+			return ErrExecArgsNotSet
+		}
+	} else if opt.Action != "restore" && !localExport && (opt.Auth == nil ||
+		(opt.Auth != nil && (opt.Auth.Username == "" || opt.Auth.Password == ""))) {
+		return ErrCredentialNotFound
+	}
+
+	if opt.Logs == nil {
+		return ErrLogsNotDefined
+	}
+
+	if opt.Action == "sync-manifest" && opt.Manifest == "" {
+		return ErrManifestNotSet
+	}
+
+	if !validDivergence(opt.Divergence) {
+		return ErrDivergenceNotValid
+	}
+
+	if !validExportFrom(opt.ExportFrom) {
+		return ErrExportFromNotValid
+	}
+
+	// Everything else is done by go-git, so the git binary is only checked when it's used
+	if opt.Action == "restore" || opt.Divergence == DivergenceMerge || opt.Divergence == DivergenceRebase {
+		return lookGit()
+	}
+
+	return nil
+}
+
+// Check the git binary can be found
+func lookGit() error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return ErrGitNotFound
+	}
+	return nil
+}
+
+// Create the event bus of the run, subscribing the report, the logger
+// and the observers of the options
+func (c *engine) startRun() {
+	c.events = newEventBus(append([]Observer{c.report, &logObserver{log: c.log}}, c.observers...)...)
+}
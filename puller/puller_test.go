@@ -0,0 +1,15 @@
+package puller
+
+import (
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+var Log *zap.Logger
+
+func TestMain(m *testing.M) {
+	Log, _ = zap.NewDevelopment()
+	os.Exit(m.Run())
+}
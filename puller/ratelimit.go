@@ -0,0 +1,153 @@
+package puller
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/xanzy/go-gitlab"
+	"golang.org/x/time/rate"
+)
+
+const (
+	headerRetryAfter     = "Retry-After"
+	headerRateRemaining  = "RateLimit-Remaining"
+	headerRateReset      = "RateLimit-Reset"
+	apiBackoffBase       = 500 * time.Millisecond
+	apiBackoffMax        = 30 * time.Second
+	apiBackoffJitterPart = 0.2
+)
+
+// apiLimiter throttle request to the gitlab api. Beside the fixed requests
+// per second limit, it pause every request once the server tell us that
+// there is no remaining request left until the rate limit window reset.
+type apiLimiter struct {
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// Create limiter with the given requests per second.
+// Zero or negative value means there is no fixed limit.
+func newAPILimiter(rps float64) *apiLimiter {
+	limit := rate.Inf
+	burst := 0
+	if rps > 0 {
+		limit = rate.Limit(rps)
+		burst = int(math.Max(1, rps))
+	}
+
+	return &apiLimiter{
+		limiter: rate.NewLimiter(limit, burst),
+	}
+}
+
+// Wait block until the request is allowed to be sent
+func (l *apiLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	wait := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return l.limiter.Wait(ctx)
+}
+
+// Observe the rate limit headers from every response
+// and pause the next request when the quota has been used up.
+func (l *apiLimiter) observe(_ retryablehttp.Logger, resp *http.Response) {
+	if resp == nil || resp.Header.Get(headerRateRemaining) != "0" {
+		return
+	}
+
+	reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64)
+	if err != nil || reset <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Unix(reset, 0); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Decide how long to wait before retrying the failed request.
+// Retry-After and RateLimit-Reset header is honored when it's present,
+// otherwise the wait grow exponentially from the number of attempt.
+func apiBackoff(_, _ time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get(headerRetryAfter)); ok {
+			return wait
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64); err == nil && reset > 0 {
+				if wait := time.Until(time.Unix(reset, 0)); wait > 0 {
+					return wait
+				}
+			}
+		}
+	}
+
+	return exponentialBackoff(apiBackoffBase, apiBackoffMax, attemptNum)
+}
+
+// Parse Retry-After header value, which can be in seconds or a http date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// Return base * 2^attempt capped by max, with a bit of jitter
+// so concurrent request not retrying at the same time
+func exponentialBackoff(base, max time.Duration, attempt int) time.Duration {
+	wait := float64(base) * math.Pow(2, float64(attempt))
+	if wait > float64(max) {
+		wait = float64(max)
+	}
+
+	jitter := wait * apiBackoffJitterPart * rand.Float64()
+	return time.Duration(wait - jitter)
+}
+
+// Client options for making gitlab request being throttled
+// and retried politely when the server is busy
+func gitlabLimiterOptions(rps float64) []gitlab.ClientOptionFunc {
+	limiter := newAPILimiter(rps)
+	return []gitlab.ClientOptionFunc{
+		gitlab.WithCustomLimiter(limiter),
+		gitlab.WithResponseLogHook(limiter.observe),
+		gitlab.WithCustomBackoff(apiBackoff),
+	}
+}
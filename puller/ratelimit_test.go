@@ -0,0 +1,94 @@
+package puller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestApiBackoff(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Resp    *http.Response
+		Min     time.Duration
+		Max     time.Duration
+		Attempt int
+	}{
+		{
+			Name:    "RetryAfterSeconds",
+			Resp:    &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{headerRetryAfter: {"3"}}},
+			Min:     3 * time.Second,
+			Max:     3 * time.Second,
+			Attempt: 0,
+		},
+		{
+			Name:    "RateLimitReset",
+			Resp:    rateLimited(time.Now().Add(10 * time.Second)),
+			Min:     8 * time.Second,
+			Max:     10 * time.Second,
+			Attempt: 0,
+		},
+		{
+			Name:    "ServerErrorExponential",
+			Resp:    &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}},
+			Min:     time.Duration(float64(4*apiBackoffBase) * (1 - apiBackoffJitterPart)),
+			Max:     4 * apiBackoffBase,
+			Attempt: 2,
+		},
+		{
+			Name:    "CappedByMax",
+			Resp:    nil,
+			Min:     time.Duration(float64(apiBackoffMax) * (1 - apiBackoffJitterPart)),
+			Max:     apiBackoffMax,
+			Attempt: 20,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			wait := apiBackoff(0, 0, test.Attempt, test.Resp)
+			require.GreaterOrEqual(t, wait, test.Min)
+			require.LessOrEqual(t, wait, test.Max)
+		})
+	}
+}
+
+func rateLimited(reset time.Time) *http.Response {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+	return resp
+}
+
+func TestGitlabRetryAndPageError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			// Server busy, ask to retry immediately
+			w.Header().Set(headerRetryAfter, "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("X-Next-Page", "2")
+			_, _ = w.Write([]byte(`[{"id":1,"name":"WingsDev"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	opts := append(gitlabLimiterOptions(0), gitlab.WithBaseURL(server.URL))
+	client, err := gitlab.NewClient("token", opts...)
+	require.Nil(t, err)
+
+	groups, err := getRootGroups(context.Background(), client)
+	require.NotNil(t, err)
+	require.Nil(t, groups)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
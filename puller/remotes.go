@@ -0,0 +1,144 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.uber.org/zap"
+)
+
+const (
+	// Remote of the original repository of a fork
+	upstreamRemoteName = "upstream"
+)
+
+// Remote the branch is pulled from: upstream when the repository
+// has it (a fork), otherwise origin
+func sourceRemote(repo *git.Repository) string {
+	if _, err := repo.Remote(upstreamRemoteName); err == nil {
+		return upstreamRemoteName
+	}
+	return git.DefaultRemoteName
+}
+
+// Fetch every remote of the repository. The credential is only sent
+// to the remote on the same host as origin, the other one is fetched anonymously.
+func (n *node) fetchRemotes(ctx context.Context, repo *git.Repository, auth *http.BasicAuth, log *zap.Logger, allHeads bool) (int, error) {
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return 0, err
+	}
+
+	attempts := 0
+	origin := remoteURL(n.path)
+	for _, remote := range remotes {
+		attempts, err = n.fetchRemote(ctx, remote, remoteAuth(origin, remote, auth), log, allHeads)
+		if err != nil {
+			return attempts, err
+		}
+	}
+	return attempts, nil
+}
+
+// Return the credential sent to the remote, nil when the remote is not
+// on the same host as origin so the credential never leak to another host
+func remoteAuth(origin string, remote *git.Remote, auth *http.BasicAuth) *http.BasicAuth {
+	if len(remote.Config().URLs) == 0 || !sameHost(origin, remote.Config().URLs[0]) {
+		return nil
+	}
+	return auth
+}
+
+// Return the http credential of the auth, nil when the repository is accessed anonymously
+func basicAuth(auth *Auth) *http.BasicAuth {
+	if auth == nil {
+		return nil
+	}
+	return &http.BasicAuth{Username: auth.Username, Password: auth.Password}
+}
+
+// Return the credential as transport auth, nil interface when there's none
+// so go-git still use the credential of the url
+func transportAuth(auth *http.BasicAuth) transport.AuthMethod {
+	if auth == nil {
+		return nil
+	}
+	return auth
+}
+
+// Fetch the remote using its configured refspecs, or every branch of
+// the remote when allHeads is set (the refspecs of a single branch clone only has one)
+func (n *node) fetchRemote(ctx context.Context, remote *git.Remote, auth *http.BasicAuth, log *zap.Logger, allHeads bool) (int, error) {
+	name := remote.Config().Name
+	opt := &git.FetchOptions{
+		RemoteName: name,
+		Progress:   n.events.progressWriter(n.path, ActionUpdate, PhaseFetch),
+	}
+	if auth != nil {
+		opt.Auth = auth
+	}
+	if allHeads {
+		opt.RefSpecs = []config.RefSpec{config.RefSpec(fmt.Sprintf("+refs/heads/*:refs/remotes/%v/*", name))}
+	}
+
+	attempts, err := n.retry.do(ctx, log, "fetch "+name, func() error {
+		err := remote.FetchContext(ctx, opt)
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return attempts, fmt.Errorf("fetch remote %v: %w", name, err)
+	}
+	log.Debug("Fetched remote", zap.String("remote", name))
+	return attempts, nil
+}
+
+// Push the branch pulled from upstream into origin, so the fork follow its upstream.
+// Nothing is pushed unless the push origin option is set and the branch come from upstream.
+func (n *node) pushUpstream(ctx context.Context, repo *git.Repository, branch plumbing.ReferenceName, source string, auth *http.BasicAuth, log *zap.Logger) error {
+	if !n.allRemotes || !n.pushOrigin || source == git.DefaultRemoteName {
+		return nil
+	}
+
+	n.events.phase(n.path, ActionUpdate, PhasePush)
+	_, err := n.retry.do(ctx, log, "push", func() error {
+		err := repo.PushContext(ctx, &git.PushOptions{
+			RemoteName: git.DefaultRemoteName,
+			RefSpecs:   []config.RefSpec{config.RefSpec(branch + ":" + branch)},
+			Auth:       transportAuth(auth),
+			Progress:   n.events.progressWriter(n.path, ActionUpdate, PhasePush),
+		})
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("push to origin: %w", err)
+	}
+	return nil
+}
+
+// Check whether both remote urls point to the same host. Local path
+// and scp-like url (git@host:path) don't have a host, they're the same only
+// when both of them don't have it.
+func sameHost(a string, b string) bool {
+	return remoteHost(a) == remoteHost(b)
+}
+
+func remoteHost(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
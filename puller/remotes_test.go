@@ -0,0 +1,205 @@
+package puller
+
+import (
+	"context"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSameHost(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{"Same Host", "https://gitlab.com/team/api.git", "https://gitlab.com/upstream/api.git", true},
+		{"Other Host", "https://gitlab.com/team/api.git", "https://github.com/upstream/api.git", false},
+		{"Other Port", "http://localhost:8080/api.git", "http://localhost/api.git", false},
+		{"Local Path", "/srv/git/api.git", "/srv/git/upstream.git", true},
+		{"Local And Remote", "/srv/git/api.git", "https://gitlab.com/api.git", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, sameHost(tt.a, tt.b))
+		})
+	}
+}
+
+// Create fork of the upstream on the bare directory, and a local clone
+// of the fork having the upstream as its upstream remote
+func newTestFork(t *testing.T, dir string, upstream *testRemote) (*git.Repository, *git.Repository) {
+	fork, err := git.PlainClone(dir+"/bare/fork.git", true, &git.CloneOptions{URL: upstream.url})
+	require.Nil(t, err)
+
+	local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: dir + "/bare/fork.git"})
+	require.Nil(t, err)
+
+	_, err = local.CreateRemote(&config.RemoteConfig{Name: upstreamRemoteName, URLs: []string{upstream.url}})
+	require.Nil(t, err)
+	return fork, local
+}
+
+func TestUpdateRepoAllRemotes(t *testing.T) {
+	head := func(repo *git.Repository, name plumbing.ReferenceName) string {
+		ref, err := repo.Reference(name, true)
+		require.Nil(t, err)
+		return ref.Hash().String()
+	}
+
+	tests := []struct {
+		name       string
+		allRemotes bool
+		pushOrigin bool
+		fromRemote bool
+		pushed     bool
+		status     string
+	}{
+		{"Origin Only", false, false, false, false, StatusUpToDate},
+		{"Pull Upstream", true, false, true, false, StatusSuccess},
+		{"Push Origin", true, true, true, true, StatusSuccess},
+		{"Push Without All Remotes", false, true, false, false, StatusUpToDate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			upstream := newTestRemote(t, dir, "upstream")
+			fork, local := newTestFork(t, dir, upstream)
+			forkHead := head(fork, plumbing.Master)
+
+			upstream.commit(t, "main.go", "package main")
+			upstreamHead := head(upstream.work, plumbing.Master)
+
+			r := &report{}
+			n := makeNode(&nodeOptions{
+				path:       dir + "/local",
+				log:        Log,
+				auth:       &Auth{Username: "user", Password: "pass"},
+				events:     newEventBus(r),
+				allRemotes: tt.allRemotes,
+				pushOrigin: tt.pushOrigin,
+			})
+			require.Nil(t, n.updateRepo(context.Background()))
+			require.Equal(t, tt.status, r.results[0].Status)
+
+			if tt.fromRemote {
+				require.Equal(t, upstreamHead, head(local, plumbing.Master))
+				require.Equal(t, upstreamHead, head(local, plumbing.NewRemoteReferenceName(upstreamRemoteName, "master")))
+			} else {
+				require.Equal(t, forkHead, head(local, plumbing.Master))
+			}
+
+			if tt.pushed {
+				require.Equal(t, upstreamHead, head(fork, plumbing.Master))
+			} else {
+				require.Equal(t, forkHead, head(fork, plumbing.Master))
+			}
+		})
+	}
+}
+
+func TestUpdateRepoAllRemotesFailed(t *testing.T) {
+	dir := t.TempDir()
+	upstream := newTestRemote(t, dir, "upstream")
+	_, local := newTestFork(t, dir, upstream)
+
+	_, err := local.CreateRemote(&config.RemoteConfig{Name: "mirror", URLs: []string{dir + "/bare/missing.git"}})
+	require.Nil(t, err)
+
+	r := &report{}
+	n := makeNode(&nodeOptions{
+		path:       dir + "/local",
+		log:        Log,
+		auth:       &Auth{Username: "user", Password: "pass"},
+		events:     newEventBus(r),
+		allRemotes: true,
+	})
+	require.NotNil(t, n.updateRepo(context.Background()))
+	require.Equal(t, StatusFailed, r.results[0].Status)
+	require.Contains(t, r.results[0].Err.Error(), "fetch remote mirror")
+}
+
+// gitHTTPServer serve the bare repositories of a directory over smart http
+// (git http-backend), recording the Authorization header of every request
+type gitHTTPServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	auths []string
+}
+
+func newGitHTTPServer(t *testing.T, root string) *gitHTTPServer {
+	path, err := exec.LookPath("git")
+	require.Nil(t, err)
+	backend := &cgi.Handler{
+		Path: path,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+
+	s := &gitHTTPServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.auths = append(s.auths, r.Header.Get("Authorization"))
+		s.mu.Unlock()
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *gitHTTPServer) authorizations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.auths...)
+}
+
+func TestUpdateRepoAllRemotesOtherHost(t *testing.T) {
+	dir := t.TempDir()
+	upstream := newTestRemote(t, dir, "upstream")
+	_, err := git.PlainClone(dir+"/bare/fork.git", true, &git.CloneOptions{URL: upstream.url})
+	require.Nil(t, err)
+
+	// Origin and upstream are served by two hosts (the port is part of the host)
+	originHost := newGitHTTPServer(t, dir+"/bare")
+	upstreamHost := newGitHTTPServer(t, dir+"/bare")
+	local, err := git.PlainClone(dir+"/local", false, &git.CloneOptions{URL: originHost.URL + "/fork.git"})
+	require.Nil(t, err)
+	_, err = local.CreateRemote(&config.RemoteConfig{Name: upstreamRemoteName, URLs: []string{upstreamHost.URL + "/upstream.git"}})
+	require.Nil(t, err)
+
+	upstream.commit(t, "main.go", "package main")
+	cloned := len(originHost.authorizations())
+	r := &report{}
+	n := makeNode(&nodeOptions{
+		path:       dir + "/local",
+		log:        Log,
+		auth:       &Auth{Username: "user", Password: "pass"},
+		events:     newEventBus(r),
+		retry:      RetryPolicy{MaxAttempts: 1},
+		allRemotes: true,
+	})
+	require.Nil(t, n.updateRepo(context.Background()))
+	require.Equal(t, StatusSuccess, r.results[0].Status)
+	require.FileExists(t, dir+"/local/main.go")
+
+	// The credential is only sent to the host of origin, not even by the pull
+	require.NotEmpty(t, upstreamHost.authorizations())
+	for _, auth := range upstreamHost.authorizations() {
+		require.Empty(t, auth)
+	}
+	require.Greater(t, len(originHost.authorizations()), cloned)
+	for _, auth := range originHost.authorizations()[cloned:] {
+		require.NotEmpty(t, auth)
+	}
+}
@@ -0,0 +1,73 @@
+package puller
+
+import (
+	"sort"
+	"sync"
+)
+
+// Action done on the repository
+const (
+	ActionClone  = "clone"
+	ActionUpdate = "update"
+)
+
+// Status of the repository at the end of the run
+const (
+	StatusSuccess  = "success"
+	StatusFailed   = "failed"
+	StatusSkipped  = "skipped"
+	StatusUpToDate = "up-to-date"
+)
+
+// report collect the result of every repository in the run from the finished events,
+// it's safe to be used by concurrent goroutines
+type report struct {
+	mu      sync.Mutex
+	results []RepoResult
+
+	// repositories found on the disk that are not in the manifest
+	extras []string
+}
+
+// Record result of the finished repository
+func (r *report) OnEvent(e Event) {
+	if e.Result == nil || (e.Type != EventCloneFinished && e.Type != EventUpdateFinished) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, *e.Result)
+}
+
+// Return copy of the results in the order of their path, like the summary of the command line,
+// so the result doesn't depend on which worker finished first
+func (r *report) list() []RepoResult {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := append([]RepoResult(nil), r.results...)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results
+}
+
+// Record the repositories that are not in the manifest
+func (r *report) setExtras(extras []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extras = extras
+}
+
+// Return copy of the repositories that are not in the manifest
+func (r *report) extraList() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.extras...)
+}
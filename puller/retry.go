@@ -0,0 +1,173 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.uber.org/zap"
+)
+
+// RetryPolicy define how a failed git network operation (clone, fetch and pull)
+// is being repeated. Only transient failure like timeout, connection reset
+// or server error is retried, other failure is returned right away.
+type RetryPolicy struct {
+	// Maximum number of attempt including the first one
+	MaxAttempts int
+
+	// Wait before the second attempt, it will be doubled on every next attempt
+	Backoff time.Duration
+
+	// Upper bound of the wait between attempt
+	MaxBackoff time.Duration
+
+	// Fraction of the wait (0 - 1) being randomized,
+	// so concurrent operations don't retry at the same time
+	Jitter float64
+}
+
+// Retry policy used when none being set on the options
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     2 * time.Second,
+	MaxBackoff:  time.Minute,
+	Jitter:      0.2,
+}
+
+var ErrRetryExhausted = errors.New("Retry attempts exhausted")
+
+// exhaustedError is the failure of the last attempt, it's both ErrRetryExhausted
+// and its cause so the cause can still be checked with errors.Is and errors.As
+type exhaustedError struct {
+	attempts int
+	err      error
+}
+
+func (e *exhaustedError) Error() string {
+	return fmt.Sprintf("%v after %v attempts: %v", ErrRetryExhausted, e.attempts, e.err)
+}
+
+func (e *exhaustedError) Is(target error) bool {
+	return target == ErrRetryExhausted
+}
+
+func (e *exhaustedError) Unwrap() error {
+	return e.err
+}
+
+// Execute the operation until it succeed, the error is not retryable
+// or the maximum attempt has been reached. It return the number of attempt used.
+func (p RetryPolicy) do(ctx context.Context, log *zap.Logger, name string, fn func() error) (int, error) {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil {
+			return attempt, nil
+		}
+
+		if ctx.Err() != nil || !isRetryable(err) {
+			return attempt, err
+		}
+
+		if attempt >= maxAttempts {
+			if maxAttempts == 1 {
+				return attempt, err
+			}
+			return attempt, &exhaustedError{attempts: attempt, err: err}
+		}
+
+		wait := p.wait(attempt)
+		log.Debug("Operation failed, retrying", zap.String("operation", name), zap.Int("attempt", attempt),
+			zap.Int("max_attempts", maxAttempts), zap.Duration("wait", wait), zap.Error(err))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// Calculate the wait before the next attempt
+func (p RetryPolicy) wait(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		wait -= time.Duration(float64(wait) * p.Jitter * rand.Float64())
+	}
+	return wait
+}
+
+// Classify whether the error is a transient failure worth to be retried:
+// network timeout, connection reset/refused, unexpected EOF, server error (5xx),
+// too many requests and reference changed while being updated concurrently.
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var lfsErr *lfsHTTPError
+	if errors.As(err, &lfsErr) {
+		return lfsErr.code >= 500 || lfsErr.code == 429
+	}
+
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		var httpErr *githttp.Err
+		if errors.As(unexpected.Err, &httpErr) {
+			code := httpErr.StatusCode()
+			return code >= 500 || code == 429
+		}
+	}
+
+	// go-git doesn't always wrap the underlying error,
+	// fallback to check the message
+	msg := strings.ToLower(err.Error())
+	for _, transient := range []string{
+		"reference has changed concurrently",
+		"connection reset",
+		"connection refused",
+		"i/o timeout",
+		"tls handshake timeout",
+		"unexpected eof",
+	} {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+
+	return false
+}
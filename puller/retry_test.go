@@ -0,0 +1,98 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "dial tcp: i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func statusErr(code int) error {
+	req := &http.Request{URL: &url.URL{Scheme: "http", Host: "gitlab"}}
+	return plumbing.NewUnexpectedError(&githttp.Err{Response: &http.Response{StatusCode: code, Request: req}})
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Err      error
+		Expected bool
+	}{
+		{Name: "Nil", Err: nil, Expected: false},
+		{Name: "Timeout", Err: timeoutErr{}, Expected: true},
+		{Name: "ConnectionReset", Err: fmt.Errorf("read: %w", syscall.ECONNRESET), Expected: true},
+		{Name: "ServerError", Err: statusErr(http.StatusBadGateway), Expected: true},
+		{Name: "TooManyRequests", Err: statusErr(http.StatusTooManyRequests), Expected: true},
+		{Name: "BadRequest", Err: statusErr(http.StatusBadRequest), Expected: false},
+		{Name: "AuthRequired", Err: transport.ErrAuthenticationRequired, Expected: false},
+		{Name: "RefChanged", Err: errors.New("reference has changed concurrently"), Expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			require.Equal(t, test.Expected, isRetryable(test.Err))
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	log := zap.NewNop()
+
+	t.Run("SucceedAfterRetry", func(t *testing.T) {
+		calls := 0
+		attempts, err := policy.do(context.Background(), log, "test", func() error {
+			calls++
+			if calls < 2 {
+				return timeoutErr{}
+			}
+			return nil
+		})
+		require.Nil(t, err)
+		require.Equal(t, 2, attempts)
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		attempts, err := policy.do(context.Background(), log, "test", func() error {
+			return timeoutErr{}
+		})
+		require.True(t, errors.Is(err, ErrRetryExhausted))
+		require.Equal(t, 3, attempts)
+
+		// The cause is still in the error chain
+		var cause timeoutErr
+		require.True(t, errors.As(err, &cause))
+		require.Equal(t, "Retry attempts exhausted after 3 attempts: "+cause.Error(), err.Error())
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		attempts, err := policy.do(context.Background(), log, "test", func() error {
+			return transport.ErrAuthenticationRequired
+		})
+		require.Equal(t, transport.ErrAuthenticationRequired, err)
+		require.Equal(t, 1, attempts)
+	})
+}
+
+func TestRetryWait(t *testing.T) {
+	policy := RetryPolicy{Backoff: 1, MaxBackoff: 4}
+	require.EqualValues(t, 1, policy.wait(1))
+	require.EqualValues(t, 2, policy.wait(2))
+	require.EqualValues(t, 4, policy.wait(3))
+	require.EqualValues(t, 4, policy.wait(10))
+}
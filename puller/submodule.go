@@ -0,0 +1,72 @@
+package puller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.uber.org/zap"
+)
+
+// SubmoduleResult is the result of a submodule updated inside the repository
+type SubmoduleResult struct {
+	// Path of the submodule relative to the repository
+	Path string
+
+	// Nil when the submodule (and its nested submodules) is updated
+	Err error
+}
+
+// Initialize and update every submodule of the repository, recursively, to the
+// commit recorded by the repository. Submodule already checked out on that commit
+// is not fetched again. Failure is only recorded in the result, so the other
+// submodule is still updated and the repository itself is not failed.
+func updateSubmodules(ctx context.Context, log *zap.Logger, retry RetryPolicy, path string, auth *http.BasicAuth) []SubmoduleResult {
+	log = log.With(zap.String("repo", path), zap.String("action", PhaseSubmodule))
+
+	repo, err := openRepo(path)
+	if err != nil {
+		return []SubmoduleResult{{Path: ".", Err: err}}
+	}
+
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return []SubmoduleResult{{Path: ".", Err: err}}
+	}
+
+	subs, err := workTree.Submodules()
+	if err != nil {
+		return []SubmoduleResult{{Path: ".", Err: err}}
+	}
+
+	results := make([]SubmoduleResult, 0, len(subs))
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		start := time.Now()
+		name := sub.Config().Path
+		if status, err := sub.Status(); err == nil && status.IsClean() {
+			log.Debug("Submodule is up-to-date", zap.String("submodule", name))
+			results = append(results, SubmoduleResult{Path: name})
+			continue
+		}
+
+		_, err := retry.do(ctx, log, "submodule update", func() error {
+			return sub.UpdateContext(ctx, &git.SubmoduleUpdateOptions{
+				Init:              true,
+				RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+				Auth:              transportAuth(auth),
+			})
+		})
+		if err != nil {
+			log.Warn("Failed to update submodule", zap.String("submodule", name), zap.Error(err))
+		} else {
+			log.Debug("Finish updating submodule", zap.String("submodule", name), zap.Duration("duration", time.Since(start)))
+		}
+		results = append(results, SubmoduleResult{Path: name, Err: err})
+	}
+	return results
+}
@@ -0,0 +1,101 @@
+package puller
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// Run git binary inside the directory, go-git can't add a submodule
+func runGit(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-c", "protocol.file.allow=always", "-c", "user.name=tester", "-c", "user.email=tester@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.Nil(t, err, string(output))
+}
+
+// Add the submodule into the remote and push it
+func (r *testRemote) addSubmodule(t *testing.T, sub *testRemote, path string) {
+	wt, err := r.work.Worktree()
+	require.Nil(t, err)
+
+	runGit(t, wt.Filesystem.Root(), "submodule", "add", sub.url, path)
+	runGit(t, wt.Filesystem.Root(), "commit", "-m", "add submodule "+path)
+	require.Nil(t, r.work.Push(&git.PushOptions{RemoteName: "bare"}))
+}
+
+// Move the submodule of the remote to the latest commit of its own remote
+func (r *testRemote) bumpSubmodule(t *testing.T, path string) {
+	wt, err := r.work.Worktree()
+	require.Nil(t, err)
+
+	runGit(t, wt.Filesystem.Root()+"/"+path, "pull", "origin", "master")
+	runGit(t, wt.Filesystem.Root(), "commit", "-am", "bump submodule "+path)
+	require.Nil(t, r.work.Push(&git.PushOptions{RemoteName: "bare"}))
+}
+
+func TestSubmodules(t *testing.T) {
+	ws := newTestWorkspace(t)
+
+	lib := newTestRemote(t, ws.dir, "lib")
+	app := newTestRemote(t, ws.dir, "app")
+	app.addSubmodule(t, lib, "vendor/lib")
+	ws.gitlab.addProject(1, "app", app.url)
+
+	// Submodule pointing to a repository that doesn't exist
+	broken := newTestRemote(t, ws.dir, "broken")
+	broken.addSubmodule(t, lib, "lib")
+	wt, _ := broken.work.Worktree()
+	runGit(t, wt.Filesystem.Root(), "config", "-f", ".gitmodules", "submodule.lib.url", ws.dir+"/bare/missing.git")
+	runGit(t, wt.Filesystem.Root(), "commit", "-am", "break submodule")
+	require.Nil(t, broken.work.Push(&git.PushOptions{RemoteName: "bare"}))
+	ws.gitlab.addProject(1, "broken", broken.url)
+
+	submodules := func(res *Result) map[string][]SubmoduleResult {
+		subs := make(map[string][]SubmoduleResult)
+		for _, repo := range res.Repos {
+			subs[repo.Path[len(ws.root)+1:]] = repo.Submodules
+		}
+		return subs
+	}
+
+	s, _ := ws.syncer(t, Options{Submodules: true})
+	res, err := s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, StatusSuccess, statusByPath(ws.root, res)["Team/app"])
+	require.Equal(t, []SubmoduleResult{{Path: "vendor/lib"}}, submodules(res)["Team/app"])
+	require.FileExists(t, ws.root+"/Team/app/vendor/lib/README.md")
+
+	// Failed submodule is reported on its parent without failing it
+	require.Equal(t, StatusSuccess, statusByPath(ws.root, res)["Team/broken"])
+	require.Len(t, submodules(res)["Team/broken"], 1)
+	require.NotNil(t, submodules(res)["Team/broken"][0].Err)
+
+	// Repository without submodule has nothing to report
+	require.Empty(t, submodules(res)["Team/api"])
+
+	// Pulled repository checkout the new commit of the submodule
+	lib.commit(t, "lib.go", "package lib")
+	app.bumpSubmodule(t, "vendor/lib")
+
+	res, err = s.UpdateGitlab(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, StatusSuccess, statusByPath(ws.root, res)["Team/app"])
+	require.Equal(t, []SubmoduleResult{{Path: "vendor/lib"}}, submodules(res)["Team/app"])
+	content, err := os.ReadFile(ws.root + "/Team/app/vendor/lib/lib.go")
+	require.Nil(t, err)
+	require.Equal(t, "package lib", string(content))
+
+	// Without the option the submodule is left uninitialized
+	s, _ = ws.syncer(t, Options{})
+	require.Nil(t, os.RemoveAll(ws.root+"/Team/app"))
+	res, err = s.CloneGitlab(context.Background())
+	require.Nil(t, err)
+	require.Empty(t, submodules(res)["Team/app"])
+	require.NoFileExists(t, ws.root+"/Team/app/vendor/lib/README.md")
+}
@@ -0,0 +1,212 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrOptionsNotSet = errors.New("Options has not been set")
+
+// RepoResult is the result of a single repository
+type RepoResult struct {
+	// Local path of the repository
+	Path string
+
+	// ActionClone or ActionUpdate
+	Action string
+
+	// StatusSuccess, StatusFailed, StatusSkipped or StatusUpToDate
+	Status string
+
+	// Number of attempts of the network operation, retry included
+	Attempts int
+
+	// Reason of the failure, nil unless the status is StatusFailed
+	Err error
+
+	// Hooks being run for the repository
+	Hooks []HookResult
+
+	// Submodules updated inside the repository, only set with the submodules option
+	Submodules []SubmoduleResult
+
+	// LFS objects fetched into the worktree, only set with the LFS option
+	// when the repository use LFS
+	LFS *LFSResult
+
+	// Local branches compared with their remote branch, only set with the all branches option
+	Branches []BranchResult
+
+	// Files having conflict when the diverged branch was merged or rebased,
+	// the branch is left untouched and the status is StatusFailed
+	Conflicts []string
+
+	// Backup of the uncommitted change taken before the worktree is reset or checked out,
+	// or of the local commits before the rebase rewrite them. Empty when nothing was discarded
+	Backup string
+
+	// Time spent on the repository
+	Duration time.Duration
+}
+
+// HookResult is the result of a hook run inside the repository
+type HookResult struct {
+	// pre-update, post-clone or post-update
+	Name string
+
+	// Combined stdout and stderr of the hook
+	Output string
+
+	// Nil when the hook exit successfully
+	Err error
+}
+
+// Result of a sync run, the repositories are ordered by their path
+type Result struct {
+	Repos []RepoResult
+
+	// Repositories found on the disk that are not in the manifest, only set by SyncManifest
+	Extras []string
+}
+
+// Syncer clone and update repositories for a program embedding the puller,
+// the command line is built on it as well. It never prints anything, the result
+// of every repository is returned and every event is delivered to the observers of the options.
+// A Syncer can be used for several runs, even concurrently.
+type Syncer struct {
+	cmd *engine
+}
+
+// Create syncer from the options. Action and Verbose option is ignored,
+// a no-op logger is used when the logger is not set. The onEvent callback
+// is an additional observer, it can be nil.
+func NewSyncer(opt *Options, onEvent func(Event)) (*Syncer, error) {
+	if opt == nil {
+		return nil, ErrOptionsNotSet
+	}
+
+	o := *opt
+	if o.Logs == nil {
+		o.Logs = zap.NewNop()
+	}
+
+	if onEvent != nil {
+		o.Observers = append(append([]Observer(nil), o.Observers...), ObserverFunc(onEvent))
+	}
+
+	cmd, err := newEngine(&o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Syncer{cmd: cmd}, nil
+}
+
+// Update every repository inside the directory of the options
+func (s *Syncer) Update(ctx context.Context) (*Result, error) {
+	return s.run(ctx, (*engine).UpdateGit)
+}
+
+// Update the gitlab tree, cloning project that doesn't exist yet
+func (s *Syncer) UpdateGitlab(ctx context.Context) (*Result, error) {
+	return s.run(ctx, (*engine).UpdateGitlab)
+}
+
+// Clone the gitlab project that doesn't exist yet
+func (s *Syncer) CloneGitlab(ctx context.Context) (*Result, error) {
+	return s.run(ctx, (*engine).CloneGitlab)
+}
+
+// Make the directory match the manifest of the options
+func (s *Syncer) SyncManifest(ctx context.Context) (*Result, error) {
+	return s.run(ctx, (*engine).SyncManifest)
+}
+
+// Build the manifest of the directory, or of the gitlab tree with ExportGitlab,
+// holding the url, the branch and the commit of every repository. Nothing is written.
+func (s *Syncer) Export(ctx context.Context) (*Manifest, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	c := *s.cmd
+	c.report = &report{}
+	return c.exportManifest(ctx)
+}
+
+// Run the exec arguments of the options inside every repository of the directory,
+// the output of the command is prefixed with the path of the repository and written into out.
+// The results are in the order of the path, ErrExecFailed is returned when any command failed.
+func (s *Syncer) Exec(ctx context.Context, out io.Writer) ([]ExecResult, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.cmd.exec(ctx, out)
+}
+
+// List the backups of every repository inside the directory having one,
+// ErrGitNotFound is returned when the git binary is not found
+func (s *Syncer) Backups(ctx context.Context) ([]RepoBackups, error) {
+	if err := lookGit(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	repos, err := s.cmd.localRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.cmd.listBackups(ctx, repos)
+}
+
+// Restore the backup into every repository inside the directory having it,
+// BackupLatest restore the newest backup of every repository.
+// ErrRestoreFailed is returned when any repository failed to be restored.
+func (s *Syncer) Restore(ctx context.Context, name string) ([]RestoreResult, error) {
+	if err := lookGit(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	repos, err := s.cmd.localRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.cmd.restoreBackups(ctx, repos, name)
+}
+
+// Run the action on its own copy of the command, so every run has its own result
+func (s *Syncer) run(ctx context.Context, action func(*engine, context.Context) error) (*Result, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	c := *s.cmd
+	c.report = &report{}
+	err := action(&c, ctx)
+
+	return &Result{Repos: c.report.list(), Extras: c.report.extraList()}, err
+}
+
+// Limit the context by the timeout of the whole run, when it's set
+func (s *Syncer) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.cmd.timeout > 0 {
+		return context.WithTimeout(ctx, s.cmd.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Return the repositories that failed
+func (r *Result) Failed() []RepoResult {
+	failed := make([]RepoResult, 0)
+	for _, repo := range r.Repos {
+		if repo.Status == StatusFailed {
+			failed = append(failed, repo)
+		}
+	}
+	return failed
+}
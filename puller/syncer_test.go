@@ -0,0 +1,99 @@
+package puller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSyncer(t *testing.T) {
+	_, err := NewSyncer(nil, nil)
+	require.Equal(t, ErrOptionsNotSet, err)
+
+	_, err = NewSyncer(&Options{Dir: t.TempDir()}, nil)
+	require.Equal(t, ErrCredentialNotFound, err)
+
+	s, err := NewSyncer(&Options{Dir: t.TempDir(), Auth: &Auth{Username: "user", Password: "pass"}}, nil)
+	require.Nil(t, err)
+	require.NotNil(t, s.cmd)
+}
+
+func TestSyncerUpdate(t *testing.T) {
+	root := t.TempDir()
+	remotes := t.TempDir()
+	first := initTestRepo(t, remotes+"/first")
+	initTestRepo(t, remotes+"/second")
+
+	for _, name := range []string{"first", "second"} {
+		_, err := git.PlainClone(root+"/group/"+name, false, &git.CloneOptions{URL: remotes + "/" + name})
+		require.Nil(t, err)
+	}
+	commitTestFile(t, first, "main.go", "package main")
+
+	r := &recorder{}
+	s, err := NewSyncer(&Options{
+		Dir:       root,
+		Auth:      &Auth{Username: "user", Password: "pass"},
+		Observers: []Observer{r},
+	}, nil)
+	require.Nil(t, err)
+
+	res, err := s.Update(context.Background())
+	require.Nil(t, err)
+	require.Len(t, res.Repos, 2)
+	require.Empty(t, res.Failed())
+
+	for i, status := range []string{StatusSuccess, StatusUpToDate} {
+		require.Equal(t, status, res.Repos[i].Status)
+		require.Equal(t, ActionUpdate, res.Repos[i].Action)
+		require.Equal(t, 1, res.Repos[i].Attempts)
+		require.Nil(t, res.Repos[i].Err)
+	}
+
+	// Every repository is discovered, started then finished
+	for _, repo := range res.Repos {
+		events := r.repo(repo.Path)
+		require.GreaterOrEqual(t, len(events), 3)
+		require.Equal(t, EventRepoDiscovered, events[0].Type)
+		require.Equal(t, EventUpdateStarted, events[1].Type)
+		require.Equal(t, EventUpdateFinished, events[len(events)-1].Type)
+		require.Equal(t, repo.Status, events[len(events)-1].Result.Status)
+	}
+
+	// Updated repository report the backup, checkout and fetch phase
+	var phases []string
+	for _, e := range r.repo(root + "/group/first") {
+		if e.Type == EventUpdateProgress && e.Message == "" {
+			phases = append(phases, e.Phase)
+		}
+	}
+	require.Equal(t, []string{PhaseBackup, PhaseCheckout, PhaseFetch}, phases)
+
+	var called int32
+	s, err = NewSyncer(&Options{Dir: root, Auth: &Auth{Username: "user", Password: "pass"}}, func(e Event) {
+		atomic.AddInt32(&called, 1)
+	})
+	require.Nil(t, err)
+
+	// Every run has its own result
+	res, err = s.Update(context.Background())
+	require.Nil(t, err)
+	require.Len(t, res.Repos, 2)
+	require.Greater(t, atomic.LoadInt32(&called), int32(0))
+}
+
+func TestResultOrderedByPath(t *testing.T) {
+	r := &report{}
+	for _, path := range []string{"/ws/team/web", "/ws/libs/util", "/ws/team/api"} {
+		r.OnEvent(Event{Type: EventUpdateFinished, Path: path, Result: &RepoResult{Path: path, Status: StatusSuccess}})
+	}
+
+	paths := make([]string, 0)
+	for _, repo := range r.list() {
+		paths = append(paths, repo.Path)
+	}
+	require.Equal(t, []string{"/ws/libs/util", "/ws/team/api", "/ws/team/web"}, paths)
+}
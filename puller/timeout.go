@@ -0,0 +1,29 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrRepoTimeout = errors.New("Repository operation timed out")
+
+// Limit the context of a single repository clone/pull with the repo timeout.
+// Zero or negative timeout means the operation is only bounded by the parent context.
+func withRepoTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Replace the error of a repository operation that ran out of its own time,
+// so it's reported as timed out instead of a generic context error.
+// Error caused by the parent context (canceled or whole run timed out) is kept as it is.
+func repoTimeoutError(parent, repoCtx context.Context, timeout time.Duration, err error) error {
+	if err == nil || parent.Err() != nil || !errors.Is(repoCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w after %v: %v", ErrRepoTimeout, timeout, err)
+}
@@ -0,0 +1,44 @@
+package puller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoTimeoutError(t *testing.T) {
+	errClone := errors.New("clone failed")
+
+	t.Run("RepoTimedOut", func(t *testing.T) {
+		parent := context.Background()
+		repoCtx, cancel := withRepoTimeout(parent, time.Nanosecond)
+		defer cancel()
+		<-repoCtx.Done()
+
+		err := repoTimeoutError(parent, repoCtx, time.Nanosecond, errClone)
+		require.True(t, errors.Is(err, ErrRepoTimeout))
+	})
+
+	t.Run("ParentCanceled", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(context.Background())
+		repoCtx, cancel := withRepoTimeout(parent, time.Minute)
+		defer cancel()
+		cancelParent()
+
+		err := repoTimeoutError(parent, repoCtx, time.Minute, errClone)
+		require.Equal(t, errClone, err)
+	})
+
+	t.Run("NoTimeout", func(t *testing.T) {
+		parent := context.Background()
+		repoCtx, cancel := withRepoTimeout(parent, 0)
+		defer cancel()
+
+		_, hasDeadline := repoCtx.Deadline()
+		require.False(t, hasDeadline)
+		require.Nil(t, repoTimeoutError(parent, repoCtx, 0, nil))
+	})
+}